@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"path/filepath"
 	"strings"
 
 	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
@@ -12,10 +13,45 @@ import (
 type Config struct {
 	Actions FactoryOpts
 
-	StartContainersWithSystemD bool              `json:"start_containers_with_systemd"`
-	MountCgroupfs              bool              `json:"mount_cgroupfs"`
-	EnableLXCFSSupport         bool              `json:"enable_lxcfs_support"`
-	LightStemcell              LightStemcellOpts `json:"light_stemcell"`
+	// StartContainersWithSystemD is detected from the stemcell when not set
+	StartContainersWithSystemD *bool `json:"start_containers_with_systemd"`
+	// MountCgroupfs is enabled on cgroup v2 hosts when not set
+	MountCgroupfs      *bool             `json:"mount_cgroupfs"`
+	EnableLXCFSSupport bool              `json:"enable_lxcfs_support"`
+	LightStemcell      LightStemcellOpts `json:"light_stemcell"`
+
+	// HelperImage is used for containers that run commands on the Docker host
+	HelperImage string        `json:"helper_image"`
+	Disks       DisksOpts     `json:"disks"`
+	Snapshots   SnapshotsOpts `json:"snapshots"`
+	VMs         VMsOpts       `json:"vms"`
+
+	EphemeralDisks EphemeralDisksOpts `json:"ephemeral_disks"`
+
+	// Rootless is set when the Docker daemon is rootless or remaps user
+	// namespaces; it is detected from the daemon when not set
+	Rootless *bool `json:"rootless"`
+
+	// Security is the default security profile of containers;
+	// VM types can replace it
+	Security SecurityOpts `json:"security"`
+
+	// PreservedPaths are carried over when a container is recreated;
+	// DefaultPreservedPaths are used when not set
+	PreservedPaths []string `json:"preserved_paths"`
+
+	// PublishAllPorts publishes exposed ports on random host ports;
+	// enabled when not set
+	PublishAllPorts *bool `json:"publish_all_ports"`
+}
+
+// DefaultPreservedPaths hold the state the agent writes outside of the
+// ephemeral volume that it expects to find after a restart
+var DefaultPreservedPaths = []string{
+	"/var/vcap/bosh/update_settings.json",
+	"/var/vcap/bosh/spec.json",
+	"/var/vcap/instance",
+	"/var/vcap/monit/job",
 }
 
 type DockerOpts struct {
@@ -64,6 +100,159 @@ type LightStemcellOpts struct {
 	RequireImageVerification bool `json:"require_image_verification"`
 }
 
+type DisksOpts struct {
+	// EnforceSize backs persistent disks with loop files of the requested size
+	EnforceSize bool `json:"enforce_size"`
+
+	// LoopDir is the directory on the Docker host that holds loop files
+	LoopDir string `json:"loop_dir"`
+
+	// HotAttach mounts persistent disks into running containers
+	// instead of recreating them
+	HotAttach bool `json:"hot_attach"`
+
+	// HotAttachDir is the directory on the Docker host that holds
+	// per-container mount points of hot attached disks
+	HotAttachDir string `json:"hot_attach_dir"`
+
+	// MetadataDir is the directory on the Docker host that holds disk metadata;
+	// metadata is discarded when empty
+	MetadataDir string `json:"metadata_dir"`
+}
+
+func (o DisksOpts) Validate() error {
+	if o.EnforceSize && len(o.LoopDir) == 0 {
+		return bosherr.Error("Must provide non-empty LoopDir when EnforceSize is enabled")
+	}
+
+	if len(o.LoopDir) > 0 && !filepath.IsAbs(o.LoopDir) {
+		return bosherr.Errorf("Must provide absolute LoopDir, got '%s'", o.LoopDir)
+	}
+
+	if o.HotAttach && !filepath.IsAbs(o.HotAttachDir) {
+		return bosherr.Errorf("Must provide absolute HotAttachDir when HotAttach is enabled, got '%s'", o.HotAttachDir)
+	}
+
+	if len(o.MetadataDir) > 0 && !filepath.IsAbs(o.MetadataDir) {
+		return bosherr.Errorf("Must provide absolute MetadataDir, got '%s'", o.MetadataDir)
+	}
+
+	return nil
+}
+
+type SnapshotsOpts struct {
+	// Dir is the directory on the Docker host that holds snapshot archives;
+	// snapshots are disabled when empty
+	Dir string `json:"dir"`
+}
+
+func (o SnapshotsOpts) Validate() error {
+	if len(o.Dir) > 0 && !filepath.IsAbs(o.Dir) {
+		return bosherr.Errorf("Must provide absolute Dir, got '%s'", o.Dir)
+	}
+
+	return nil
+}
+
+type VMsOpts struct {
+	// MetadataDir is the directory on the Docker host that holds VM metadata;
+	// metadata is discarded when empty
+	MetadataDir string `json:"metadata_dir"`
+}
+
+func (o VMsOpts) Validate() error {
+	if len(o.MetadataDir) > 0 && !filepath.IsAbs(o.MetadataDir) {
+		return bosherr.Errorf("Must provide absolute MetadataDir, got '%s'", o.MetadataDir)
+	}
+
+	return nil
+}
+
+type EphemeralDisksOpts struct {
+	// Type (loop or tmpfs) sizes ephemeral disks of VMs created from
+	// vm_resources; their size is not enforced when empty
+	Type string `json:"type"`
+}
+
+func (o EphemeralDisksOpts) Validate() error {
+	switch o.Type {
+	case "", "loop", "tmpfs":
+		return nil
+	default:
+		return bosherr.Errorf("Must provide Type 'loop' or 'tmpfs', got '%s'", o.Type)
+	}
+}
+
+// DefaultCapabilities are added to Docker's default capability set so that
+// the agent and runit or systemd can mount file systems, configure networking
+// and manage processes inside the container.
+var DefaultCapabilities = []string{
+	"SYS_ADMIN",
+	"NET_ADMIN",
+	"SYS_RESOURCE",
+	"SYS_NICE",
+	"SYS_PTRACE",
+	"DAC_READ_SEARCH",
+}
+
+const Unconfined = "unconfined"
+
+type SecurityOpts struct {
+	// Privileged runs containers privileged; other settings are ignored
+	Privileged bool `json:"privileged"`
+
+	// Capabilities are added to Docker's default set;
+	// DefaultCapabilities are used when not set
+	Capabilities []string `json:"capabilities"`
+
+	// Seccomp is a seccomp profile in JSON or "unconfined";
+	// Docker's default profile is used when empty
+	Seccomp string `json:"seccomp"`
+
+	// AppArmor is the name of a profile loaded on the Docker host;
+	// containers are unconfined when empty since Docker's default profile
+	// denies the mounts done by the agent
+	AppArmor string `json:"apparmor"`
+
+	// NoNewPrivileges stops processes from gaining privileges through
+	// setuid binaries such as sudo
+	NoNewPrivileges bool `json:"no_new_privileges"`
+}
+
+func (o SecurityOpts) CapabilitiesOrDefault() []string {
+	if o.Capabilities == nil {
+		return DefaultCapabilities
+	}
+
+	return o.Capabilities
+}
+
+func (o SecurityOpts) AppArmorOrDefault() string {
+	if len(o.AppArmor) == 0 {
+		return Unconfined
+	}
+
+	return o.AppArmor
+}
+
+func (o SecurityOpts) Validate() error {
+	for _, capability := range o.Capabilities {
+		if len(capability) == 0 || strings.ContainsAny(capability, " \t\n,") {
+			return bosherr.Errorf("Must provide Capabilities without whitespace or commas, got '%s'", capability)
+		}
+	}
+
+	if len(o.Seccomp) > 0 && o.Seccomp != Unconfined && !json.Valid([]byte(o.Seccomp)) {
+		return bosherr.Error("Must provide Seccomp as a JSON profile or 'unconfined'")
+	}
+
+	if strings.ContainsAny(o.AppArmor, " \t\n") {
+		return bosherr.Errorf("Must provide AppArmor without whitespace, got '%s'", o.AppArmor)
+	}
+
+	return nil
+}
+
 type FactoryOpts struct {
 	Docker DockerOpts
 	Agent  apiv1.AgentOptions
@@ -110,5 +299,59 @@ func (c Config) Validate() error {
 		return bosherr.WrapError(err, "Validating Actions configuration")
 	}
 
+	err = c.Disks.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating Disks configuration")
+	}
+
+	err = c.Snapshots.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating Snapshots configuration")
+	}
+
+	err = c.VMs.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating VMs configuration")
+	}
+
+	err = c.EphemeralDisks.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating EphemeralDisks configuration")
+	}
+
+	if c.EphemeralDisks.Type == "loop" && len(c.Disks.LoopDir) == 0 {
+		return bosherr.Error("Must provide non-empty Disks LoopDir when EphemeralDisks Type is 'loop'")
+	}
+
+	err = c.Security.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating Security configuration")
+	}
+
+	for _, p := range c.PreservedPaths {
+		if !filepath.IsAbs(p) || filepath.Clean(p) != p || p == "/" {
+			return bosherr.Errorf("Must provide clean absolute PreservedPaths other than '/', got '%s'", p)
+		}
+	}
+
 	return nil
 }
+
+// PreservedPathsOrDefault returns DefaultPreservedPaths unless PreservedPaths
+// were configured; an empty list disables preservation.
+func (c Config) PreservedPathsOrDefault() []string {
+	if c.PreservedPaths == nil {
+		return DefaultPreservedPaths
+	}
+
+	return c.PreservedPaths
+}
+
+// PublishAllPortsOrDefault returns PublishAllPorts, or true when not set.
+func (c Config) PublishAllPortsOrDefault() bool {
+	if c.PublishAllPorts == nil {
+		return true
+	}
+
+	return *c.PublishAllPorts
+}
@@ -4,12 +4,37 @@ import (
 	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
 )
 
-type CalculateVMCloudPropertiesMethod struct{}
+// cpuShares is the Docker default weight of a container, given here per CPU
+// so that bigger instances win proportionally more time under contention.
+const cpuShares = 1024
 
-func NewCalculateVMCloudPropertiesMethod() CalculateVMCloudPropertiesMethod {
-	return CalculateVMCloudPropertiesMethod{}
+type CalculateVMCloudPropertiesMethod struct {
+	ephemeralDiskType string
 }
 
-func (a CalculateVMCloudPropertiesMethod) CalculateVMCloudProperties(_ apiv1.VMResources) (apiv1.VMCloudProps, error) {
-	return apiv1.NewVMCloudPropsFromMap(map[string]interface{}{}), nil
+// NewCalculateVMCloudPropertiesMethod only sizes ephemeral disks when the
+// operator chose a type, since loop and tmpfs disks need opting in.
+func NewCalculateVMCloudPropertiesMethod(ephemeralDiskType string) CalculateVMCloudPropertiesMethod {
+	return CalculateVMCloudPropertiesMethod{ephemeralDiskType: ephemeralDiskType}
+}
+
+// CalculateVMCloudProperties translates vm_resources into the HostConfig
+// resource limits and ephemeral disk accepted by vm.Props.
+func (a CalculateVMCloudPropertiesMethod) CalculateVMCloudProperties(res apiv1.VMResources) (apiv1.VMCloudProps, error) {
+	props := map[string]interface{}{}
+
+	if res.RAM > 0 {
+		props["Memory"] = int64(res.RAM) * 1024 * 1024
+	}
+
+	if res.CPU > 0 {
+		props["NanoCpus"] = int64(res.CPU) * 1e9
+		props["CpuShares"] = int64(res.CPU) * cpuShares
+	}
+
+	if res.EphemeralDiskSize > 0 && len(a.ephemeralDiskType) > 0 {
+		props["ephemeral_disk"] = map[string]interface{}{"size": res.EphemeralDiskSize, "type": a.ephemeralDiskType}
+	}
+
+	return apiv1.NewVMCloudPropsFromMap(props), nil
 }
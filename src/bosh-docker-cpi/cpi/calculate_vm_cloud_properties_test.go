@@ -0,0 +1,65 @@
+package cpi_test
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	bvm "bosh-docker-cpi/vm"
+)
+
+var _ = Describe("CalculateVMCloudPropertiesMethod", func() {
+	var (
+		method cpi.CalculateVMCloudPropertiesMethod
+	)
+
+	BeforeEach(func() {
+		method = cpi.NewCalculateVMCloudPropertiesMethod("tmpfs")
+	})
+
+	It("returns empty cloud properties without resources", func() {
+		props, err := method.CalculateVMCloudProperties(apiv1.VMResources{})
+		Expect(err).NotTo(HaveOccurred())
+
+		vmProps := unmarshalVMProps(props)
+		Expect(vmProps).To(Equal(bvm.Props{}))
+	})
+
+	It("maps RAM, CPU and ephemeral disk size to VM properties", func() {
+		props, err := method.CalculateVMCloudProperties(apiv1.VMResources{RAM: 2048, CPU: 2, EphemeralDiskSize: 10240})
+		Expect(err).NotTo(HaveOccurred())
+
+		vmProps := unmarshalVMProps(props)
+		Expect(vmProps.Memory).To(Equal(int64(2048 * 1024 * 1024)))
+		Expect(vmProps.NanoCPUs).To(Equal(int64(2e9)))
+		Expect(vmProps.CPUShares).To(Equal(int64(2048)))
+		Expect(vmProps.EphemeralDisk).To(Equal(bvm.EphemeralDiskProps{Size: 10240, Type: "tmpfs"}))
+		Expect(vmProps.Validate()).To(Succeed())
+	})
+
+	It("does not size ephemeral disks unless a type is configured", func() {
+		method = cpi.NewCalculateVMCloudPropertiesMethod("")
+
+		props, err := method.CalculateVMCloudProperties(apiv1.VMResources{RAM: 2048, CPU: 2, EphemeralDiskSize: 10240})
+		Expect(err).NotTo(HaveOccurred())
+
+		vmProps := unmarshalVMProps(props)
+		Expect(vmProps.Memory).To(Equal(int64(2048 * 1024 * 1024)))
+		Expect(vmProps.EphemeralDisk).To(Equal(bvm.EphemeralDiskProps{}))
+	})
+})
+
+// unmarshalVMProps round-trips props through JSON like the Director does
+// before passing them to create_vm.
+func unmarshalVMProps(props apiv1.VMCloudProps) bvm.Props {
+	bytes, err := json.Marshal(props)
+	Expect(err).NotTo(HaveOccurred())
+
+	var vmProps bvm.Props
+	Expect(json.Unmarshal(bytes, &vmProps)).To(Succeed())
+
+	return vmProps
+}
@@ -16,9 +16,9 @@ func NewCreateDiskMethod(diskCreator bdisk.Creator) CreateDiskMethod {
 }
 
 func (a CreateDiskMethod) CreateDisk(
-	size int, _ apiv1.DiskCloudProps, vmCID *apiv1.VMCID) (apiv1.DiskCID, error) {
+	size int, cloudProps apiv1.DiskCloudProps, vmCID *apiv1.VMCID) (apiv1.DiskCID, error) {
 
-	disk, err := a.diskCreator.Create(size, vmCID)
+	disk, err := a.diskCreator.Create(size, cloudProps, vmCID)
 	if err != nil {
 		return apiv1.DiskCID{}, bosherr.WrapErrorf(err, "Creating disk of size '%d'", size)
 	}
@@ -35,8 +35,9 @@ var _ = Describe("CreateDiskMethod", func() {
 		Expect(cid).To(Equal(expectedCID))
 
 		Expect(fakeCreator.CreateCallCount()).To(Equal(1))
-		size, passedVMCID := fakeCreator.CreateArgsForCall(0)
+		size, passedProps, passedVMCID := fakeCreator.CreateArgsForCall(0)
 		Expect(size).To(Equal(1024))
+		Expect(passedProps).To(BeNil())
 		Expect(passedVMCID).To(Equal(&vmCID))
 	})
 
@@ -47,10 +48,23 @@ var _ = Describe("CreateDiskMethod", func() {
 		_, err := method.CreateDisk(2048, nil, nil)
 		Expect(err).NotTo(HaveOccurred())
 
-		_, passedVMCID := fakeCreator.CreateArgsForCall(0)
+		_, _, passedVMCID := fakeCreator.CreateArgsForCall(0)
 		Expect(passedVMCID).To(BeNil())
 	})
 
+	It("passes disk cloud properties to the creator", func() {
+		fakeDisk.IDReturns(apiv1.NewDiskCID("vol-new-disk"))
+		fakeCreator.CreateReturns(fakeDisk, nil)
+
+		props := apiv1.CloudPropsImpl{RawMessage: []byte(`{"driver": "local"}`)}
+
+		_, err := method.CreateDisk(2048, props, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, passedProps, _ := fakeCreator.CreateArgsForCall(0)
+		Expect(passedProps).To(Equal(props))
+	})
+
 	It("returns error when creating the disk fails", func() {
 		fakeCreator.CreateReturns(nil, errors.New("create-error"))
 
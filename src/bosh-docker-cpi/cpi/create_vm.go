@@ -37,10 +37,10 @@ func (a CreateVMMethod) CreateVMV2(
 		return apiv1.VMCID{}, networks, bosherr.WrapErrorf(err, "Finding stemcell '%s'", stemcellCID)
 	}
 
-	vm, err := a.vmCreator.Create(agentID, stemcell, cloudProps, networks, diskCIDs, env)
+	vm, assignedNetworks, err := a.vmCreator.Create(agentID, stemcell, cloudProps, networks, diskCIDs, env)
 	if err != nil {
 		return apiv1.VMCID{}, networks, bosherr.WrapErrorf(err, "Creating VM with agent ID '%s'", agentID)
 	}
 
-	return vm.ID(), networks, nil
+	return vm.ID(), assignedNetworks, nil
 }
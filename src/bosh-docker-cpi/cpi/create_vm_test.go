@@ -46,14 +46,17 @@ var _ = Describe("CreateVMMethod", func() {
 	Describe("CreateVMV2", func() {
 		It("finds the stemcell and creates a VM", func() {
 			expectedVMCID := apiv1.NewVMCID("c-new-vm")
+			assignedNetworks := apiv1.Networks{
+				"default": apiv1.NewNetwork(apiv1.NetworkOpts{Type: "dynamic", IP: "172.18.0.2"}),
+			}
 			fakeStemcellFinder.FindReturns(fakeStemcell, nil)
 			fakeVM.IDReturns(expectedVMCID)
-			fakeVMCreator.CreateReturns(fakeVM, nil)
+			fakeVMCreator.CreateReturns(fakeVM, assignedNetworks, nil)
 
 			vmCID, returnedNetworks, err := method.CreateVMV2(agentID, stemcellCID, cloudProps, networks, diskCIDs, env)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(vmCID).To(Equal(expectedVMCID))
-			Expect(returnedNetworks).To(Equal(networks))
+			Expect(returnedNetworks).To(Equal(assignedNetworks))
 
 			Expect(fakeStemcellFinder.FindCallCount()).To(Equal(1))
 			Expect(fakeStemcellFinder.FindArgsForCall(0)).To(Equal(stemcellCID))
@@ -74,7 +77,7 @@ var _ = Describe("CreateVMMethod", func() {
 
 		It("returns error when creating the VM fails", func() {
 			fakeStemcellFinder.FindReturns(fakeStemcell, nil)
-			fakeVMCreator.CreateReturns(nil, errors.New("vm-create-error"))
+			fakeVMCreator.CreateReturns(nil, nil, errors.New("vm-create-error"))
 
 			_, _, err := method.CreateVMV2(agentID, stemcellCID, cloudProps, networks, diskCIDs, env)
 			Expect(err).To(MatchError(ContainSubstring("Creating VM")))
@@ -87,7 +90,7 @@ var _ = Describe("CreateVMMethod", func() {
 			expectedVMCID := apiv1.NewVMCID("c-new-vm")
 			fakeStemcellFinder.FindReturns(fakeStemcell, nil)
 			fakeVM.IDReturns(expectedVMCID)
-			fakeVMCreator.CreateReturns(fakeVM, nil)
+			fakeVMCreator.CreateReturns(fakeVM, networks, nil)
 
 			vmCID, err := method.CreateVM(agentID, stemcellCID, cloudProps, networks, diskCIDs, env)
 			Expect(err).NotTo(HaveOccurred())
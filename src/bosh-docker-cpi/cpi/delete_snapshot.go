@@ -0,0 +1,46 @@
+package cpi
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bsnap "bosh-docker-cpi/snapshot"
+)
+
+type DeleteSnapshotMethod struct {
+	snapshotFinder bsnap.Finder
+}
+
+func NewDeleteSnapshotMethod(snapshotFinder bsnap.Finder) DeleteSnapshotMethod {
+	return DeleteSnapshotMethod{snapshotFinder: snapshotFinder}
+}
+
+// DeleteSnapshot treats IDs the CPI could not have created as already
+// deleted, e.g. empty IDs returned before snapshots were implemented.
+func (a DeleteSnapshotMethod) DeleteSnapshot(cid apiv1.SnapshotCID) error {
+	snapshot, err := a.snapshotFinder.Find(cid)
+	if err != nil {
+		if errors.Is(err, bsnap.ErrUnknownID) {
+			return nil
+		}
+
+		if errors.Is(err, bsnap.ErrDisabled) {
+			return NewNotImplementedError(err.Error())
+		}
+
+		return bosherr.WrapErrorf(err, "Finding snapshot '%s'", cid)
+	}
+
+	err = snapshot.Delete()
+	if err != nil {
+		if errors.Is(err, bsnap.ErrUnknownID) {
+			return nil
+		}
+
+		return bosherr.WrapErrorf(err, "Deleting snapshot '%s'", cid)
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package cpi_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	bsnap "bosh-docker-cpi/snapshot"
+	"bosh-docker-cpi/snapshot/snapshotfakes"
+)
+
+var _ = Describe("DeleteSnapshotMethod", func() {
+	var (
+		fakeFinder   *snapshotfakes.FakeFinder
+		fakeSnapshot *snapshotfakes.FakeSnapshot
+		method       cpi.DeleteSnapshotMethod
+		snapshotCID  apiv1.SnapshotCID
+	)
+
+	BeforeEach(func() {
+		fakeFinder = &snapshotfakes.FakeFinder{}
+		fakeSnapshot = &snapshotfakes.FakeSnapshot{}
+		fakeFinder.FindReturns(fakeSnapshot, nil)
+		method = cpi.NewDeleteSnapshotMethod(fakeFinder)
+		snapshotCID = apiv1.NewSnapshotCID("snap-123")
+	})
+
+	It("finds and deletes the snapshot", func() {
+		err := method.DeleteSnapshot(snapshotCID)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeFinder.FindArgsForCall(0)).To(Equal(snapshotCID))
+		Expect(fakeSnapshot.DeleteCallCount()).To(Equal(1))
+	})
+
+	It("returns a NotImplemented error when snapshots are disabled", func() {
+		fakeFinder.FindReturns(nil, bsnap.ErrDisabled)
+
+		err := method.DeleteSnapshot(snapshotCID)
+		Expect(err).To(BeAssignableToTypeOf(cpi.NotImplementedError{}))
+	})
+
+	It("succeeds for IDs the CPI could not have created", func() {
+		fakeFinder.FindReturns(nil, bsnap.ErrUnknownID)
+
+		Expect(method.DeleteSnapshot(apiv1.SnapshotCID{})).To(Succeed())
+	})
+
+	It("succeeds for malformed snapshot IDs", func() {
+		fakeSnapshot.DeleteReturns(bsnap.ErrUnknownID)
+
+		Expect(method.DeleteSnapshot(apiv1.NewSnapshotCID("snap-../x"))).To(Succeed())
+	})
+
+	It("returns error when deleting the snapshot fails", func() {
+		fakeSnapshot.DeleteReturns(errors.New("delete-error"))
+
+		err := method.DeleteSnapshot(snapshotCID)
+		Expect(err).To(MatchError(ContainSubstring("Deleting snapshot")))
+		Expect(err).To(MatchError(ContainSubstring("delete-error")))
+	})
+})
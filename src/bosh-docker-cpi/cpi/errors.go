@@ -0,0 +1,27 @@
+package cpi
+
+// NotImplementedError lets the Director fall back to another strategy,
+// e.g. migrating persistent disk contents through the agent.
+type NotImplementedError struct {
+	msg string
+}
+
+func NewNotImplementedError(msg string) NotImplementedError {
+	return NotImplementedError{msg: msg}
+}
+
+func (e NotImplementedError) Error() string { return e.msg }
+func (e NotImplementedError) Type() string  { return "Bosh::Clouds::NotImplemented" }
+
+// NotSupportedError lets the Director fall back to migrating disk contents
+// through the agent when update_disk cannot change a disk.
+type NotSupportedError struct {
+	msg string
+}
+
+func NewNotSupportedError(msg string) NotSupportedError {
+	return NotSupportedError{msg: msg}
+}
+
+func (e NotSupportedError) Error() string { return e.msg }
+func (e NotSupportedError) Type() string  { return "Bosh::Clouds::NotSupported" }
@@ -15,6 +15,8 @@ import (
 
 	"bosh-docker-cpi/config"
 	bdisk "bosh-docker-cpi/disk"
+	bhost "bosh-docker-cpi/host"
+	bsnap "bosh-docker-cpi/snapshot"
 	bstem "bosh-docker-cpi/stemcell"
 	bvm "bosh-docker-cpi/vm"
 )
@@ -46,11 +48,16 @@ type CPI struct {
 	AttachDiskMethod
 	DetachDiskMethod
 	HasDiskMethod
+	ResizeDiskMethod
+	UpdateDiskMethod
+	SetDiskMetadataMethod
 
-	Disks
-	Snapshots
+	SnapshotDiskMethod
+	DeleteSnapshotMethod
 }
 
+var _ apiv1.DiskUpdater = CPI{}
+
 func NewFactory(
 	fs boshsys.FileSystem,
 	uuidGen boshuuid.Generator,
@@ -67,17 +74,7 @@ func (f Factory) New(ctx apiv1.CallContext) (apiv1.CPI, error) {
 		return CPI{}, err
 	}
 
-	httpClient, err := f.httpClient(opts)
-	if err != nil {
-		return CPI{}, err
-	}
-
-	dkrClient, err :=
-		dkrclient.NewClientWithOpts(
-			dkrclient.WithHost(opts.Host),
-			dkrclient.WithVersion(opts.APIVersion),
-			dkrclient.WithHTTPClient(httpClient),
-		)
+	dkrClient, err := f.dockerClient(opts)
 	if err != nil {
 		return CPI{}, err
 	}
@@ -90,8 +87,10 @@ func (f Factory) New(ctx apiv1.CallContext) (apiv1.CPI, error) {
 		f.logger,
 	)
 	stemcellFinder := bstem.NewFSFinder(dkrClient, f.logger)
-	vmFactory := bvm.NewFactory(dkrClient, f.uuidGen, f.opts.Agent, f.logger, f.Config)
-	diskFactory := bdisk.NewFactory(dkrClient, f.uuidGen, f.logger)
+	hostRunner := bhost.NewContainerRunner(dkrClient, f.Config.HelperImage, f.logger)
+	vmFactory := bvm.NewFactory(dkrClient, hostRunner, f.uuidGen, f.opts.Agent, f.logger, f.Config)
+	diskFactory := bdisk.NewFactory(dkrClient, hostRunner, f.uuidGen, f.Config.Disks, f.logger)
+	snapshotFactory := bsnap.NewFactory(f.Config.Snapshots.Dir, hostRunner, f.uuidGen, f.logger)
 
 	return CPI{
 		NewInfoMethod(),
@@ -101,10 +100,10 @@ func (f Factory) New(ctx apiv1.CallContext) (apiv1.CPI, error) {
 
 		NewCreateVMMethod(stemcellFinder, vmFactory),
 		NewDeleteVMMethod(vmFactory),
-		NewCalculateVMCloudPropertiesMethod(),
+		NewCalculateVMCloudPropertiesMethod(f.Config.EphemeralDisks.Type),
 		NewHasVMMethod(vmFactory),
-		NewRebootVMMethod(),
-		NewSetVMMetadataMethod(),
+		NewRebootVMMethod(vmFactory),
+		NewSetVMMetadataMethod(vmFactory),
 		NewGetDisksMethod(vmFactory),
 
 		NewCreateDiskMethod(diskFactory),
@@ -112,11 +111,71 @@ func (f Factory) New(ctx apiv1.CallContext) (apiv1.CPI, error) {
 		NewAttachDiskMethod(vmFactory, diskFactory),
 		NewDetachDiskMethod(vmFactory, diskFactory),
 		NewHasDiskMethod(diskFactory),
-		NewDisks(),
-		NewSnapshots(),
+		NewResizeDiskMethod(diskFactory),
+		NewUpdateDiskMethod(diskFactory, diskFactory),
+		NewSetDiskMetadataMethod(diskFactory),
+
+		NewSnapshotDiskMethod(diskFactory, snapshotFactory),
+		NewDeleteSnapshotMethod(snapshotFactory),
 	}, nil
 }
 
+// NewListVMsMethod uses the configured Docker options since it is invoked
+// outside of a CPI request.
+func (f Factory) NewListVMsMethod() (ListVMsMethod, error) {
+	dkrClient, err := f.dockerClient(f.opts.Docker)
+	if err != nil {
+		return ListVMsMethod{}, err
+	}
+
+	hostRunner := bhost.NewContainerRunner(dkrClient, f.Config.HelperImage, f.logger)
+	vmFactory := bvm.NewFactory(dkrClient, hostRunner, f.uuidGen, f.opts.Agent, f.logger, f.Config)
+
+	return NewListVMsMethod(vmFactory), nil
+}
+
+// NewListDisksMethod uses the configured Docker options since it is invoked
+// outside of a CPI request.
+func (f Factory) NewListDisksMethod() (ListDisksMethod, error) {
+	dkrClient, err := f.dockerClient(f.opts.Docker)
+	if err != nil {
+		return ListDisksMethod{}, err
+	}
+
+	hostRunner := bhost.NewContainerRunner(dkrClient, f.Config.HelperImage, f.logger)
+	diskFactory := bdisk.NewFactory(dkrClient, hostRunner, f.uuidGen, f.Config.Disks, f.logger)
+
+	return NewListDisksMethod(diskFactory), nil
+}
+
+// NewPruneMetadataMethod uses the configured Docker options since it is invoked
+// outside of a CPI request.
+func (f Factory) NewPruneMetadataMethod() (PruneMetadataMethod, error) {
+	dkrClient, err := f.dockerClient(f.opts.Docker)
+	if err != nil {
+		return PruneMetadataMethod{}, err
+	}
+
+	hostRunner := bhost.NewContainerRunner(dkrClient, f.Config.HelperImage, f.logger)
+	vmFactory := bvm.NewFactory(dkrClient, hostRunner, f.uuidGen, f.opts.Agent, f.logger, f.Config)
+	diskFactory := bdisk.NewFactory(dkrClient, hostRunner, f.uuidGen, f.Config.Disks, f.logger)
+
+	return NewPruneMetadataMethod(vmFactory, diskFactory), nil
+}
+
+func (f Factory) dockerClient(opts config.DockerOpts) (*dkrclient.Client, error) {
+	httpClient, err := f.httpClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return dkrclient.NewClientWithOpts(
+		dkrclient.WithHost(opts.Host),
+		dkrclient.WithVersion(opts.APIVersion),
+		dkrclient.WithHTTPClient(httpClient),
+	)
+}
+
 func (Factory) dockerOpts(ctx apiv1.CallContext, defaults config.DockerOpts) (config.DockerOpts, error) {
 	var opts config.DockerOpts
 
@@ -2,6 +2,7 @@ package cpi
 
 import (
 	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 
 	bvm "bosh-docker-cpi/vm"
 )
@@ -14,6 +15,20 @@ func NewGetDisksMethod(vmFinder bvm.Finder) GetDisksMethod {
 	return GetDisksMethod{vmFinder}
 }
 
-func (a GetDisksMethod) GetDisks(_ apiv1.VMCID) ([]apiv1.DiskCID, error) { // TODO implement
-	return nil, nil
+func (a GetDisksMethod) GetDisks(cid apiv1.VMCID) ([]apiv1.DiskCID, error) {
+	vm, err := a.vmFinder.Find(cid)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Finding vm '%s'", cid)
+	}
+
+	diskIDs, err := vm.DiskIDs()
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Getting disks of vm '%s'", cid)
+	}
+
+	if diskIDs == nil {
+		diskIDs = []apiv1.DiskCID{} // the Director expects [] rather than null
+	}
+
+	return diskIDs, nil
 }
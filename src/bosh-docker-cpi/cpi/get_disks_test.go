@@ -0,0 +1,71 @@
+package cpi_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	"bosh-docker-cpi/vm/vmfakes"
+)
+
+var _ = Describe("GetDisksMethod", func() {
+	var (
+		fakeFinder *vmfakes.FakeFinder
+		fakeVM     *vmfakes.FakeVM
+		method     cpi.GetDisksMethod
+		vmCID      apiv1.VMCID
+	)
+
+	BeforeEach(func() {
+		fakeFinder = &vmfakes.FakeFinder{}
+		fakeVM = &vmfakes.FakeVM{}
+		method = cpi.NewGetDisksMethod(fakeFinder)
+		vmCID = apiv1.NewVMCID("fake-vm-id")
+	})
+
+	It("returns the disks attached to the VM", func() {
+		diskCIDs := []apiv1.DiskCID{apiv1.NewDiskCID("vol-1"), apiv1.NewDiskCID("vol-2")}
+		fakeFinder.FindReturns(fakeVM, nil)
+		fakeVM.DiskIDsReturns(diskCIDs, nil)
+
+		disks, err := method.GetDisks(vmCID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(disks).To(Equal(diskCIDs))
+
+		Expect(fakeFinder.FindArgsForCall(0)).To(Equal(vmCID))
+	})
+
+	It("returns an empty list for VMs without disks", func() {
+		fakeFinder.FindReturns(fakeVM, nil)
+		fakeVM.DiskIDsReturns(nil, nil)
+
+		disks, err := method.GetDisks(vmCID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(disks).To(Equal([]apiv1.DiskCID{}))
+
+		bytes, err := json.Marshal(disks)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(bytes)).To(Equal("[]"))
+	})
+
+	It("returns error when finding the VM fails", func() {
+		fakeFinder.FindReturns(nil, errors.New("find-error"))
+
+		_, err := method.GetDisks(vmCID)
+		Expect(err).To(MatchError(ContainSubstring("Finding vm")))
+		Expect(err).To(MatchError(ContainSubstring("find-error")))
+	})
+
+	It("returns error when getting the disks fails", func() {
+		fakeFinder.FindReturns(fakeVM, nil)
+		fakeVM.DiskIDsReturns(nil, errors.New("inspect-error"))
+
+		_, err := method.GetDisks(vmCID)
+		Expect(err).To(MatchError(ContainSubstring("Getting disks of vm")))
+		Expect(err).To(MatchError(ContainSubstring("inspect-error")))
+	})
+})
@@ -0,0 +1,40 @@
+package cpi
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bdisk "bosh-docker-cpi/disk"
+	bvm "bosh-docker-cpi/vm"
+)
+
+// ListDisksMethod is not part of the CPI API; it backs the -listDisks command
+// operators use to find which deployment and instance own a volume.
+type ListDisksMethod struct {
+	diskLister bdisk.Lister
+}
+
+func NewListDisksMethod(diskLister bdisk.Lister) ListDisksMethod {
+	return ListDisksMethod{diskLister: diskLister}
+}
+
+func (a ListDisksMethod) ListDisks(filter string) ([]bdisk.Summary, error) {
+	metadataFilter, err := bvm.NewMetadataFilter(filter)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing metadata filter")
+	}
+
+	disks, err := a.diskLister.List()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing disks")
+	}
+
+	var matching []bdisk.Summary
+
+	for _, disk := range disks {
+		if metadataFilter.Matches(disk.Metadata) {
+			matching = append(matching, disk)
+		}
+	}
+
+	return matching, nil
+}
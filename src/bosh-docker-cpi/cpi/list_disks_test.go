@@ -0,0 +1,59 @@
+package cpi_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	bdisk "bosh-docker-cpi/disk"
+	"bosh-docker-cpi/disk/diskfakes"
+)
+
+var _ = Describe("ListDisksMethod", func() {
+	var (
+		fakeLister *diskfakes.FakeLister
+		method     cpi.ListDisksMethod
+		summaries  []bdisk.Summary
+	)
+
+	BeforeEach(func() {
+		fakeLister = &diskfakes.FakeLister{}
+		method = cpi.NewListDisksMethod(fakeLister)
+
+		summaries = []bdisk.Summary{
+			{ID: apiv1.NewDiskCID("vol-1"), Metadata: map[string]string{"deployment": "cf", "instance_group": "db"}},
+			{ID: apiv1.NewDiskCID("vol-2"), Metadata: map[string]string{"deployment": "cf", "instance_group": "router"}},
+			{ID: apiv1.NewDiskCID("vol-3")},
+		}
+		fakeLister.ListReturns(summaries, nil)
+	})
+
+	It("lists all disks including ones without metadata when no filter is given", func() {
+		disks, err := method.ListDisks("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(disks).To(Equal(summaries))
+	})
+
+	It("lists disks matching the filter", func() {
+		disks, err := method.ListDisks("deployment=cf,instance_group=db")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(disks).To(Equal(summaries[:1]))
+	})
+
+	It("returns error when the filter is malformed", func() {
+		_, err := method.ListDisks("deployment")
+		Expect(err).To(MatchError(ContainSubstring("Parsing metadata filter")))
+		Expect(fakeLister.ListCallCount()).To(Equal(0))
+	})
+
+	It("returns error when listing fails", func() {
+		fakeLister.ListReturns(nil, errors.New("list-error"))
+
+		_, err := method.ListDisks("")
+		Expect(err).To(MatchError(ContainSubstring("Listing disks")))
+		Expect(err).To(MatchError(ContainSubstring("list-error")))
+	})
+})
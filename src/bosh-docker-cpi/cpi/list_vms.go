@@ -0,0 +1,31 @@
+package cpi
+
+import (
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bvm "bosh-docker-cpi/vm"
+)
+
+// ListVMsMethod is not part of the CPI API; it backs the -listVMs command
+// operators use to find containers by deployment, job or index.
+type ListVMsMethod struct {
+	vmLister bvm.Lister
+}
+
+func NewListVMsMethod(vmLister bvm.Lister) ListVMsMethod {
+	return ListVMsMethod{vmLister: vmLister}
+}
+
+func (a ListVMsMethod) ListVMs(filter string) ([]bvm.Summary, error) {
+	metadataFilter, err := bvm.NewMetadataFilter(filter)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Parsing metadata filter")
+	}
+
+	vms, err := a.vmLister.List(metadataFilter)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing vms")
+	}
+
+	return vms, nil
+}
@@ -0,0 +1,53 @@
+package cpi_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	bvm "bosh-docker-cpi/vm"
+	"bosh-docker-cpi/vm/vmfakes"
+)
+
+var _ = Describe("ListVMsMethod", func() {
+	var (
+		fakeLister *vmfakes.FakeLister
+		method     cpi.ListVMsMethod
+	)
+
+	BeforeEach(func() {
+		fakeLister = &vmfakes.FakeLister{}
+		method = cpi.NewListVMsMethod(fakeLister)
+	})
+
+	It("lists VMs matching the parsed filter", func() {
+		summaries := []bvm.Summary{
+			{ID: apiv1.NewVMCID("c-1"), Metadata: map[string]string{"deployment": "cf"}},
+		}
+		fakeLister.ListReturns(summaries, nil)
+
+		vms, err := method.ListVMs("deployment=cf,job=router")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vms).To(Equal(summaries))
+
+		Expect(fakeLister.ListArgsForCall(0)).To(Equal(
+			bvm.MetadataFilter{"deployment": "cf", "job": "router"}))
+	})
+
+	It("returns error when the filter is malformed", func() {
+		_, err := method.ListVMs("deployment")
+		Expect(err).To(MatchError(ContainSubstring("Parsing metadata filter")))
+		Expect(fakeLister.ListCallCount()).To(Equal(0))
+	})
+
+	It("returns error when listing fails", func() {
+		fakeLister.ListReturns(nil, errors.New("list-error"))
+
+		_, err := method.ListVMs("")
+		Expect(err).To(MatchError(ContainSubstring("Listing vms")))
+		Expect(err).To(MatchError(ContainSubstring("list-error")))
+	})
+})
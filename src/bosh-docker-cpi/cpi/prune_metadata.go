@@ -0,0 +1,39 @@
+package cpi
+
+import (
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bdisk "bosh-docker-cpi/disk"
+	bvm "bosh-docker-cpi/vm"
+)
+
+// PruneMetadataMethod is not part of the CPI API; it backs the -pruneMetadata
+// command operators use to drop metadata of VMs and disks removed outside of
+// the CPI. Listing leaves such metadata alone since it may race with creation.
+type PruneMetadataMethod struct {
+	vmPruner   bvm.MetadataPruner
+	diskPruner bdisk.MetadataPruner
+}
+
+func NewPruneMetadataMethod(vmPruner bvm.MetadataPruner, diskPruner bdisk.MetadataPruner) PruneMetadataMethod {
+	return PruneMetadataMethod{vmPruner: vmPruner, diskPruner: diskPruner}
+}
+
+func (a PruneMetadataMethod) PruneVMMetadata() ([]apiv1.VMCID, error) {
+	vmIDs, err := a.vmPruner.PruneMetadata()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Pruning VM metadata")
+	}
+
+	return vmIDs, nil
+}
+
+func (a PruneMetadataMethod) PruneDiskMetadata() ([]apiv1.DiskCID, error) {
+	diskIDs, err := a.diskPruner.PruneMetadata()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Pruning disk metadata")
+	}
+
+	return diskIDs, nil
+}
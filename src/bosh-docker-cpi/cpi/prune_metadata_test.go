@@ -0,0 +1,63 @@
+package cpi_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	"bosh-docker-cpi/disk/diskfakes"
+	"bosh-docker-cpi/vm/vmfakes"
+)
+
+var _ = Describe("PruneMetadataMethod", func() {
+	var (
+		fakeVMPruner   *vmfakes.FakeMetadataPruner
+		fakeDiskPruner *diskfakes.FakeMetadataPruner
+		method         cpi.PruneMetadataMethod
+	)
+
+	BeforeEach(func() {
+		fakeVMPruner = &vmfakes.FakeMetadataPruner{}
+		fakeDiskPruner = &diskfakes.FakeMetadataPruner{}
+		method = cpi.NewPruneMetadataMethod(fakeVMPruner, fakeDiskPruner)
+	})
+
+	Describe("PruneVMMetadata", func() {
+		It("returns VMs whose metadata was pruned", func() {
+			fakeVMPruner.PruneMetadataReturns([]apiv1.VMCID{apiv1.NewVMCID("c-1")}, nil)
+
+			vmIDs, err := method.PruneVMMetadata()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(vmIDs).To(Equal([]apiv1.VMCID{apiv1.NewVMCID("c-1")}))
+		})
+
+		It("returns error when pruning fails", func() {
+			fakeVMPruner.PruneMetadataReturns(nil, errors.New("prune-error"))
+
+			_, err := method.PruneVMMetadata()
+			Expect(err).To(MatchError(ContainSubstring("Pruning VM metadata")))
+			Expect(err).To(MatchError(ContainSubstring("prune-error")))
+		})
+	})
+
+	Describe("PruneDiskMetadata", func() {
+		It("returns disks whose metadata was pruned", func() {
+			fakeDiskPruner.PruneMetadataReturns([]apiv1.DiskCID{apiv1.NewDiskCID("vol-1")}, nil)
+
+			diskIDs, err := method.PruneDiskMetadata()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diskIDs).To(Equal([]apiv1.DiskCID{apiv1.NewDiskCID("vol-1")}))
+		})
+
+		It("returns error when pruning fails", func() {
+			fakeDiskPruner.PruneMetadataReturns(nil, errors.New("prune-error"))
+
+			_, err := method.PruneDiskMetadata()
+			Expect(err).To(MatchError(ContainSubstring("Pruning disk metadata")))
+			Expect(err).To(MatchError(ContainSubstring("prune-error")))
+		})
+	})
+})
@@ -2,14 +2,29 @@ package cpi
 
 import (
 	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bvm "bosh-docker-cpi/vm"
 )
 
-type RebootVMMethod struct{}
+type RebootVMMethod struct {
+	vmFinder bvm.Finder
+}
 
-func NewRebootVMMethod() RebootVMMethod {
-	return RebootVMMethod{}
+func NewRebootVMMethod(vmFinder bvm.Finder) RebootVMMethod {
+	return RebootVMMethod{vmFinder: vmFinder}
 }
 
-func (a RebootVMMethod) RebootVM(_ apiv1.VMCID) error {
+func (a RebootVMMethod) RebootVM(cid apiv1.VMCID) error {
+	vm, err := a.vmFinder.Find(cid)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding vm '%s'", cid)
+	}
+
+	err = vm.Reboot()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Rebooting vm '%s'", cid)
+	}
+
 	return nil
 }
@@ -0,0 +1,56 @@
+package cpi_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	"bosh-docker-cpi/vm/vmfakes"
+)
+
+var _ = Describe("RebootVMMethod", func() {
+	var (
+		fakeFinder *vmfakes.FakeFinder
+		fakeVM     *vmfakes.FakeVM
+		method     cpi.RebootVMMethod
+		vmCID      apiv1.VMCID
+	)
+
+	BeforeEach(func() {
+		fakeFinder = &vmfakes.FakeFinder{}
+		fakeVM = &vmfakes.FakeVM{}
+		method = cpi.NewRebootVMMethod(fakeFinder)
+		vmCID = apiv1.NewVMCID("fake-vm-id")
+	})
+
+	It("finds and reboots the VM", func() {
+		fakeFinder.FindReturns(fakeVM, nil)
+
+		err := method.RebootVM(vmCID)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeFinder.FindCallCount()).To(Equal(1))
+		Expect(fakeFinder.FindArgsForCall(0)).To(Equal(vmCID))
+		Expect(fakeVM.RebootCallCount()).To(Equal(1))
+	})
+
+	It("returns error when finding the VM fails", func() {
+		fakeFinder.FindReturns(nil, errors.New("find-error"))
+
+		err := method.RebootVM(vmCID)
+		Expect(err).To(MatchError(ContainSubstring("Finding vm")))
+		Expect(err).To(MatchError(ContainSubstring("find-error")))
+	})
+
+	It("returns error when rebooting the VM fails", func() {
+		fakeFinder.FindReturns(fakeVM, nil)
+		fakeVM.RebootReturns(errors.New("reboot-error"))
+
+		err := method.RebootVM(vmCID)
+		Expect(err).To(MatchError(ContainSubstring("Rebooting vm")))
+		Expect(err).To(MatchError(ContainSubstring("reboot-error")))
+	})
+})
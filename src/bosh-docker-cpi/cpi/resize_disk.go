@@ -0,0 +1,36 @@
+package cpi
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bdisk "bosh-docker-cpi/disk"
+)
+
+type ResizeDiskMethod struct {
+	diskFinder bdisk.Finder
+}
+
+func NewResizeDiskMethod(diskFinder bdisk.Finder) ResizeDiskMethod {
+	return ResizeDiskMethod{diskFinder: diskFinder}
+}
+
+func (a ResizeDiskMethod) ResizeDisk(cid apiv1.DiskCID, size int) error {
+	disk, err := a.diskFinder.Find(cid)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding disk '%s'", cid)
+	}
+
+	err = disk.Resize(size)
+	if err != nil {
+		if errors.Is(err, bdisk.ErrNotResizable) {
+			return NewNotImplementedError("Resizing disk '" + cid.AsString() + "' in place is not supported")
+		}
+
+		return bosherr.WrapErrorf(err, "Resizing disk '%s'", cid)
+	}
+
+	return nil
+}
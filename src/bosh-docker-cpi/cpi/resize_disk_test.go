@@ -0,0 +1,65 @@
+package cpi_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	bdisk "bosh-docker-cpi/disk"
+	"bosh-docker-cpi/disk/diskfakes"
+)
+
+var _ = Describe("ResizeDiskMethod", func() {
+	var (
+		fakeFinder *diskfakes.FakeFinder
+		fakeDisk   *diskfakes.FakeDisk
+		method     cpi.ResizeDiskMethod
+		diskCID    apiv1.DiskCID
+	)
+
+	BeforeEach(func() {
+		fakeFinder = &diskfakes.FakeFinder{}
+		fakeDisk = &diskfakes.FakeDisk{}
+		fakeFinder.FindReturns(fakeDisk, nil)
+		method = cpi.NewResizeDiskMethod(fakeFinder)
+		diskCID = apiv1.NewDiskCID("fake-disk-id")
+	})
+
+	It("finds and resizes the disk", func() {
+		err := method.ResizeDisk(diskCID, 2048)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeFinder.FindArgsForCall(0)).To(Equal(diskCID))
+		Expect(fakeDisk.ResizeCallCount()).To(Equal(1))
+		Expect(fakeDisk.ResizeArgsForCall(0)).To(Equal(2048))
+	})
+
+	It("returns a NotImplemented error when the disk cannot be resized in place", func() {
+		fakeDisk.ResizeReturns(bdisk.ErrNotResizable)
+
+		err := method.ResizeDisk(diskCID, 2048)
+		Expect(err).To(HaveOccurred())
+
+		typedErr, ok := err.(interface{ Type() string })
+		Expect(ok).To(BeTrue())
+		Expect(typedErr.Type()).To(Equal("Bosh::Clouds::NotImplemented"))
+	})
+
+	It("returns error when finding the disk fails", func() {
+		fakeFinder.FindReturns(nil, errors.New("find-error"))
+
+		err := method.ResizeDisk(diskCID, 2048)
+		Expect(err).To(MatchError(ContainSubstring("Finding disk")))
+	})
+
+	It("returns error when resizing the disk fails", func() {
+		fakeDisk.ResizeReturns(errors.New("resize-error"))
+
+		err := method.ResizeDisk(diskCID, 2048)
+		Expect(err).To(MatchError(ContainSubstring("Resizing disk")))
+		Expect(err).To(MatchError(ContainSubstring("resize-error")))
+	})
+})
@@ -0,0 +1,30 @@
+package cpi
+
+import (
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bdisk "bosh-docker-cpi/disk"
+)
+
+type SetDiskMetadataMethod struct {
+	diskFinder bdisk.Finder
+}
+
+func NewSetDiskMetadataMethod(diskFinder bdisk.Finder) SetDiskMetadataMethod {
+	return SetDiskMetadataMethod{diskFinder: diskFinder}
+}
+
+func (a SetDiskMetadataMethod) SetDiskMetadata(cid apiv1.DiskCID, meta apiv1.DiskMeta) error {
+	disk, err := a.diskFinder.Find(cid)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding disk '%s'", cid)
+	}
+
+	err = disk.SetMetadata(meta)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Setting metadata on disk '%s'", cid)
+	}
+
+	return nil
+}
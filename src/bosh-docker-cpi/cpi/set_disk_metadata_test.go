@@ -0,0 +1,58 @@
+package cpi_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	"bosh-docker-cpi/disk/diskfakes"
+)
+
+var _ = Describe("SetDiskMetadataMethod", func() {
+	var (
+		fakeFinder *diskfakes.FakeFinder
+		fakeDisk   *diskfakes.FakeDisk
+		method     cpi.SetDiskMetadataMethod
+		diskCID    apiv1.DiskCID
+		meta       apiv1.DiskMeta
+	)
+
+	BeforeEach(func() {
+		fakeFinder = &diskfakes.FakeFinder{}
+		fakeDisk = &diskfakes.FakeDisk{}
+		method = cpi.NewSetDiskMetadataMethod(fakeFinder)
+		diskCID = apiv1.NewDiskCID("fake-disk-id")
+		meta = apiv1.NewDiskMeta(map[string]interface{}{"deployment": "cf", "instance_group": "db"})
+	})
+
+	It("finds the disk and sets its metadata", func() {
+		fakeFinder.FindReturns(fakeDisk, nil)
+
+		err := method.SetDiskMetadata(diskCID, meta)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeFinder.FindArgsForCall(0)).To(Equal(diskCID))
+		Expect(fakeDisk.SetMetadataCallCount()).To(Equal(1))
+		Expect(fakeDisk.SetMetadataArgsForCall(0)).To(Equal(meta))
+	})
+
+	It("returns error when finding the disk fails", func() {
+		fakeFinder.FindReturns(nil, errors.New("find-error"))
+
+		err := method.SetDiskMetadata(diskCID, meta)
+		Expect(err).To(MatchError(ContainSubstring("Finding disk")))
+		Expect(err).To(MatchError(ContainSubstring("find-error")))
+	})
+
+	It("returns error when setting metadata fails", func() {
+		fakeFinder.FindReturns(fakeDisk, nil)
+		fakeDisk.SetMetadataReturns(errors.New("set-error"))
+
+		err := method.SetDiskMetadata(diskCID, meta)
+		Expect(err).To(MatchError(ContainSubstring("Setting metadata on disk")))
+		Expect(err).To(MatchError(ContainSubstring("set-error")))
+	})
+})
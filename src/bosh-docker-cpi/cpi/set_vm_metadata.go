@@ -2,14 +2,29 @@ package cpi
 
 import (
 	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bvm "bosh-docker-cpi/vm"
 )
 
-type SetVMMetadataMethod struct{}
+type SetVMMetadataMethod struct {
+	vmFinder bvm.Finder
+}
 
-func NewSetVMMetadataMethod() SetVMMetadataMethod {
-	return SetVMMetadataMethod{}
+func NewSetVMMetadataMethod(vmFinder bvm.Finder) SetVMMetadataMethod {
+	return SetVMMetadataMethod{vmFinder: vmFinder}
 }
 
-func (a SetVMMetadataMethod) SetVMMetadata(_ apiv1.VMCID, _ apiv1.VMMeta) error { // TODO: can properties be set on the container
+func (a SetVMMetadataMethod) SetVMMetadata(cid apiv1.VMCID, meta apiv1.VMMeta) error {
+	vm, err := a.vmFinder.Find(cid)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Finding vm '%s'", cid)
+	}
+
+	err = vm.SetMetadata(meta)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Setting metadata on vm '%s'", cid)
+	}
+
 	return nil
 }
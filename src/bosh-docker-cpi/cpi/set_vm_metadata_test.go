@@ -0,0 +1,58 @@
+package cpi_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	"bosh-docker-cpi/vm/vmfakes"
+)
+
+var _ = Describe("SetVMMetadataMethod", func() {
+	var (
+		fakeFinder *vmfakes.FakeFinder
+		fakeVM     *vmfakes.FakeVM
+		method     cpi.SetVMMetadataMethod
+		vmCID      apiv1.VMCID
+		meta       apiv1.VMMeta
+	)
+
+	BeforeEach(func() {
+		fakeFinder = &vmfakes.FakeFinder{}
+		fakeVM = &vmfakes.FakeVM{}
+		method = cpi.NewSetVMMetadataMethod(fakeFinder)
+		vmCID = apiv1.NewVMCID("fake-vm-id")
+		meta = apiv1.NewVMMeta(map[string]interface{}{"deployment": "cf", "job": "router", "index": "0"})
+	})
+
+	It("finds the VM and sets its metadata", func() {
+		fakeFinder.FindReturns(fakeVM, nil)
+
+		err := method.SetVMMetadata(vmCID, meta)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeFinder.FindArgsForCall(0)).To(Equal(vmCID))
+		Expect(fakeVM.SetMetadataCallCount()).To(Equal(1))
+		Expect(fakeVM.SetMetadataArgsForCall(0)).To(Equal(meta))
+	})
+
+	It("returns error when finding the VM fails", func() {
+		fakeFinder.FindReturns(nil, errors.New("find-error"))
+
+		err := method.SetVMMetadata(vmCID, meta)
+		Expect(err).To(MatchError(ContainSubstring("Finding vm")))
+		Expect(err).To(MatchError(ContainSubstring("find-error")))
+	})
+
+	It("returns error when setting metadata fails", func() {
+		fakeFinder.FindReturns(fakeVM, nil)
+		fakeVM.SetMetadataReturns(errors.New("set-error"))
+
+		err := method.SetVMMetadata(vmCID, meta)
+		Expect(err).To(MatchError(ContainSubstring("Setting metadata on vm")))
+		Expect(err).To(MatchError(ContainSubstring("set-error")))
+	})
+})
@@ -0,0 +1,47 @@
+package cpi
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bdisk "bosh-docker-cpi/disk"
+	bsnap "bosh-docker-cpi/snapshot"
+)
+
+type SnapshotDiskMethod struct {
+	diskFinder      bdisk.Finder
+	snapshotCreator bsnap.Creator
+}
+
+func NewSnapshotDiskMethod(diskFinder bdisk.Finder, snapshotCreator bsnap.Creator) SnapshotDiskMethod {
+	return SnapshotDiskMethod{diskFinder: diskFinder, snapshotCreator: snapshotCreator}
+}
+
+func (a SnapshotDiskMethod) SnapshotDisk(diskCID apiv1.DiskCID, meta apiv1.DiskMeta) (apiv1.SnapshotCID, error) {
+	disk, err := a.diskFinder.Find(diskCID)
+	if err != nil {
+		return apiv1.SnapshotCID{}, bosherr.WrapErrorf(err, "Finding disk '%s'", diskCID)
+	}
+
+	exists, err := disk.Exists()
+	if err != nil {
+		return apiv1.SnapshotCID{}, bosherr.WrapErrorf(err, "Finding disk '%s'", diskCID)
+	}
+
+	if !exists {
+		return apiv1.SnapshotCID{}, bosherr.Errorf("Expected disk '%s' to exist", diskCID.AsString())
+	}
+
+	snapshot, err := a.snapshotCreator.Create(diskCID, meta)
+	if err != nil {
+		if errors.Is(err, bsnap.ErrDisabled) {
+			return apiv1.SnapshotCID{}, NewNotImplementedError(err.Error())
+		}
+
+		return apiv1.SnapshotCID{}, bosherr.WrapErrorf(err, "Snapshotting disk '%s'", diskCID)
+	}
+
+	return snapshot.ID(), nil
+}
@@ -0,0 +1,74 @@
+package cpi_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	"bosh-docker-cpi/disk/diskfakes"
+	bsnap "bosh-docker-cpi/snapshot"
+	"bosh-docker-cpi/snapshot/snapshotfakes"
+)
+
+var _ = Describe("SnapshotDiskMethod", func() {
+	var (
+		fakeDiskFinder *diskfakes.FakeFinder
+		fakeDisk       *diskfakes.FakeDisk
+		fakeCreator    *snapshotfakes.FakeCreator
+		method         cpi.SnapshotDiskMethod
+		diskCID        apiv1.DiskCID
+		meta           apiv1.DiskMeta
+	)
+
+	BeforeEach(func() {
+		fakeDiskFinder = &diskfakes.FakeFinder{}
+		fakeDisk = &diskfakes.FakeDisk{}
+		fakeDisk.ExistsReturns(true, nil)
+		fakeDiskFinder.FindReturns(fakeDisk, nil)
+		fakeCreator = &snapshotfakes.FakeCreator{}
+		method = cpi.NewSnapshotDiskMethod(fakeDiskFinder, fakeCreator)
+		diskCID = apiv1.NewDiskCID("fake-disk-id")
+		meta = apiv1.NewDiskMeta(map[string]interface{}{"deployment": "cf"})
+	})
+
+	It("returns the CID of the created snapshot", func() {
+		fakeSnapshot := &snapshotfakes.FakeSnapshot{}
+		fakeSnapshot.IDReturns(apiv1.NewSnapshotCID("snap-123"))
+		fakeCreator.CreateReturns(fakeSnapshot, nil)
+
+		cid, err := method.SnapshotDisk(diskCID, meta)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cid).To(Equal(apiv1.NewSnapshotCID("snap-123")))
+
+		actualDiskCID, actualMeta := fakeCreator.CreateArgsForCall(0)
+		Expect(actualDiskCID).To(Equal(diskCID))
+		Expect(actualMeta).To(Equal(meta))
+	})
+
+	It("returns error when the disk does not exist", func() {
+		fakeDisk.ExistsReturns(false, nil)
+
+		_, err := method.SnapshotDisk(diskCID, meta)
+		Expect(err).To(MatchError(ContainSubstring("Expected disk 'fake-disk-id' to exist")))
+		Expect(fakeCreator.CreateCallCount()).To(Equal(0))
+	})
+
+	It("returns a NotImplemented error when snapshots are disabled", func() {
+		fakeCreator.CreateReturns(nil, bsnap.ErrDisabled)
+
+		_, err := method.SnapshotDisk(diskCID, meta)
+		Expect(err).To(BeAssignableToTypeOf(cpi.NotImplementedError{}))
+		Expect(err.(cpi.NotImplementedError).Type()).To(Equal("Bosh::Clouds::NotImplemented"))
+	})
+
+	It("returns error when snapshotting fails", func() {
+		fakeCreator.CreateReturns(nil, errors.New("create-error"))
+
+		_, err := method.SnapshotDisk(diskCID, meta)
+		Expect(err).To(MatchError(ContainSubstring("Snapshotting disk")))
+		Expect(err).To(MatchError(ContainSubstring("create-error")))
+	})
+})
@@ -0,0 +1,37 @@
+package cpi
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	bdisk "bosh-docker-cpi/disk"
+)
+
+type UpdateDiskMethod struct {
+	diskFinder  bdisk.Finder
+	diskUpdater bdisk.Updater
+}
+
+func NewUpdateDiskMethod(diskFinder bdisk.Finder, diskUpdater bdisk.Updater) UpdateDiskMethod {
+	return UpdateDiskMethod{diskFinder: diskFinder, diskUpdater: diskUpdater}
+}
+
+func (a UpdateDiskMethod) UpdateDisk(cid apiv1.DiskCID, size int, cloudProps apiv1.DiskCloudProps) (apiv1.DiskCID, error) {
+	disk, err := a.diskFinder.Find(cid)
+	if err != nil {
+		return apiv1.DiskCID{}, bosherr.WrapErrorf(err, "Finding disk '%s'", cid)
+	}
+
+	updatedDisk, err := a.diskUpdater.Update(disk, size, cloudProps)
+	if err != nil {
+		if errors.Is(err, bdisk.ErrNotCopyable) {
+			return apiv1.DiskCID{}, NewNotSupportedError("Updating disk '" + cid.AsString() + "' is not supported")
+		}
+
+		return apiv1.DiskCID{}, bosherr.WrapErrorf(err, "Updating disk '%s'", cid)
+	}
+
+	return updatedDisk.ID(), nil
+}
@@ -0,0 +1,75 @@
+package cpi_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/cpi"
+	bdisk "bosh-docker-cpi/disk"
+	"bosh-docker-cpi/disk/diskfakes"
+)
+
+var _ = Describe("UpdateDiskMethod", func() {
+	var (
+		fakeFinder  *diskfakes.FakeFinder
+		fakeUpdater *diskfakes.FakeUpdater
+		fakeDisk    *diskfakes.FakeDisk
+		method      cpi.UpdateDiskMethod
+		diskCID     apiv1.DiskCID
+		cloudProps  apiv1.DiskCloudProps
+	)
+
+	BeforeEach(func() {
+		fakeFinder = &diskfakes.FakeFinder{}
+		fakeUpdater = &diskfakes.FakeUpdater{}
+		fakeDisk = &diskfakes.FakeDisk{}
+		fakeFinder.FindReturns(fakeDisk, nil)
+		method = cpi.NewUpdateDiskMethod(fakeFinder, fakeUpdater)
+		diskCID = apiv1.NewDiskCID("fake-disk-id")
+		cloudProps = apiv1.CloudPropsImpl{RawMessage: []byte(`{"enforce_size": true}`)}
+	})
+
+	It("returns the CID of the updated disk", func() {
+		updatedDisk := &diskfakes.FakeDisk{}
+		updatedDisk.IDReturns(apiv1.NewDiskCID("new-disk-id"))
+		fakeUpdater.UpdateReturns(updatedDisk, nil)
+
+		cid, err := method.UpdateDisk(diskCID, 4096, cloudProps)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cid).To(Equal(apiv1.NewDiskCID("new-disk-id")))
+
+		Expect(fakeFinder.FindArgsForCall(0)).To(Equal(diskCID))
+
+		disk, size, props := fakeUpdater.UpdateArgsForCall(0)
+		Expect(disk).To(Equal(fakeDisk))
+		Expect(size).To(Equal(4096))
+		Expect(props).To(Equal(cloudProps))
+	})
+
+	It("returns error when finding the disk fails", func() {
+		fakeFinder.FindReturns(nil, errors.New("find-error"))
+
+		_, err := method.UpdateDisk(diskCID, 4096, cloudProps)
+		Expect(err).To(MatchError(ContainSubstring("Finding disk")))
+		Expect(fakeUpdater.UpdateCallCount()).To(Equal(0))
+	})
+
+	It("returns NotSupported when the disk can neither be resized nor copied", func() {
+		fakeUpdater.UpdateReturns(nil, bdisk.ErrNotCopyable)
+
+		_, err := method.UpdateDisk(diskCID, 4096, cloudProps)
+		Expect(err).To(BeAssignableToTypeOf(cpi.NotSupportedError{}))
+		Expect(err.(cpi.NotSupportedError).Type()).To(Equal("Bosh::Clouds::NotSupported"))
+	})
+
+	It("returns error when updating the disk fails", func() {
+		fakeUpdater.UpdateReturns(nil, errors.New("update-error"))
+
+		_, err := method.UpdateDisk(diskCID, 4096, cloudProps)
+		Expect(err).To(MatchError(ContainSubstring("Updating disk")))
+		Expect(err).To(MatchError(ContainSubstring("update-error")))
+	})
+})
@@ -3,18 +3,30 @@ package disk
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
 
 	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	dkrcont "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	dkrvoltypes "github.com/docker/docker/api/types/volume"
 	dkrclient "github.com/docker/docker/client"
+
+	"bosh-docker-cpi/config"
+	"bosh-docker-cpi/host"
 )
 
 type Factory struct {
 	dkrClient *dkrclient.Client
 	uuidGen   boshuuid.Generator
+	runner    host.Runner
+	opts      config.DisksOpts
+	loopFiles LoopFiles
+	metaStore MetadataStore
 
 	logTag string
 	logger boshlog.Logger
@@ -22,21 +34,39 @@ type Factory struct {
 
 func NewFactory(
 	dkrClient *dkrclient.Client,
+	runner host.Runner,
 	uuidGen boshuuid.Generator,
+	opts config.DisksOpts,
 	logger boshlog.Logger,
 ) Factory {
 	return Factory{
 		dkrClient: dkrClient,
 		uuidGen:   uuidGen,
+		runner:    runner,
+		opts:      opts,
+		loopFiles: NewLoopFiles(opts.LoopDir, runner),
+		metaStore: NewMetadataStore(opts.MetadataDir, runner),
 
 		logTag: "disk.Factory",
 		logger: logger,
 	}
 }
 
-func (f Factory) Create(size int, vmCID *apiv1.VMCID) (Disk, error) {
+func (f Factory) Create(size int, cloudProps apiv1.DiskCloudProps, vmCID *apiv1.VMCID) (Disk, error) {
 	f.logger.Debug(f.logTag, "Creating disk of size '%d'", size)
 
+	var props Props
+
+	err := cloudProps.As(&props)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshaling disk properties")
+	}
+
+	err = props.Validate()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Validating disk properties")
+	}
+
 	id, err := f.uuidGen.Generate()
 	if err != nil {
 		return nil, bosherr.WrapError(err, "Generating disk ID")
@@ -44,10 +74,11 @@ func (f Factory) Create(size int, vmCID *apiv1.VMCID) (Disk, error) {
 
 	id = "vol-" + id
 
-	// todo allow other drivers
 	opts := dkrvoltypes.CreateOptions{
-		Name:   id,
-		Driver: "local",
+		Name:       id,
+		Driver:     props.DriverOrDefault(),
+		DriverOpts: props.DriverOpts,
+		Labels:     props.Labels,
 	}
 
 	if vmCID != nil {
@@ -62,16 +93,227 @@ func (f Factory) Create(size int, vmCID *apiv1.VMCID) (Disk, error) {
 		}
 	}
 
+	diskCID := apiv1.NewDiskCID(id)
+	enforceSize := props.EnforcesSize(f.opts.EnforceSize)
+
+	if enforceSize {
+		if len(f.opts.LoopDir) == 0 {
+			return nil, bosherr.Error("Enforcing disk size requires 'disks.loop_dir' to be configured")
+		}
+
+		opts.DriverOpts, err = f.loopFiles.Create(diskCID, size)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	_, err = f.dkrClient.VolumeCreate(context.TODO(), opts)
 	if err != nil {
+		if enforceSize {
+			delErr := f.loopFiles.Delete(f.loopFiles.Path(diskCID))
+			if delErr != nil {
+				f.logger.Error(f.logTag, "Failed cleaning up loop file: %s", delErr)
+			}
+		}
+
 		return nil, bosherr.WrapError(err, "Creating volume")
 	}
 
-	return NewVolume(apiv1.NewDiskCID(id), f.dkrClient, f.logger), nil
+	return NewVolume(diskCID, f.dkrClient, f.loopFiles, f.metaStore, f.logger), nil
+}
+
+// Update resizes loop file backed disks in place when their properties allow it.
+// Otherwise contents of local volumes are copied to a new disk and the old disk
+// is deleted; ErrNotCopyable is returned when that is not possible either.
+func (f Factory) Update(disk Disk, size int, cloudProps apiv1.DiskCloudProps) (Disk, error) {
+	f.logger.Debug(f.logTag, "Updating disk '%s' to size '%d'", disk.ID(), size)
+
+	var props Props
+
+	err := cloudProps.As(&props)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshaling disk properties")
+	}
+
+	err = props.Validate()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Validating disk properties")
+	}
+
+	vol, err := f.dkrClient.VolumeInspect(context.TODO(), disk.ID().AsString())
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Finding volume")
+	}
+
+	_, loopBacked := loopFilePath(disk.ID(), vol.Options)
+
+	if loopBacked && props.EnforcesSize(f.opts.EnforceSize) && sameLabels(vol.Labels, props.Labels) {
+		err := disk.Resize(size)
+		if err == nil {
+			return disk, nil
+		}
+
+		if !errors.Is(err, ErrNotResizable) {
+			return nil, bosherr.WrapError(err, "Resizing disk")
+		}
+	}
+
+	err = f.checkCopyable(vol, props)
+	if err != nil {
+		return nil, err
+	}
+
+	newDisk, err := f.Create(size, cloudProps, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = f.copyContents(disk, newDisk)
+	if err != nil {
+		f.cleanUpDisk(newDisk)
+		return nil, err
+	}
+
+	err = f.metaStore.Move(disk.ID(), newDisk.ID())
+	if err != nil {
+		f.cleanUpDisk(newDisk)
+		return nil, err
+	}
+
+	err = disk.Delete()
+	if err != nil {
+		exists, existsErr := disk.Exists()
+		if existsErr == nil && !exists {
+			// The volume is gone so the Director has to learn the new CID;
+			// only e.g. its loop file may be left behind
+			f.logger.Error(f.logTag, "Failed cleaning up disk '%s' after copying it: %s", disk.ID(), err)
+			return newDisk, nil
+		}
+
+		moveErr := f.metaStore.Move(newDisk.ID(), disk.ID())
+		if moveErr != nil {
+			f.logger.Error(f.logTag, "Failed moving metadata back to disk '%s': %s", disk.ID(), moveErr)
+		}
+
+		f.cleanUpDisk(newDisk)
+
+		return nil, bosherr.WrapErrorf(err, "Deleting disk '%s' after copying it", disk.ID())
+	}
+
+	return newDisk, nil
+}
+
+// checkCopyable only allows copying local volumes that no container uses,
+// including stopped ones that could start while the volume is copied.
+func (f Factory) checkCopyable(vol dkrvoltypes.Volume, props Props) error {
+	if vol.Driver != defaultDriver || props.DriverOrDefault() != defaultDriver {
+		return ErrNotCopyable
+	}
+
+	containers, err := f.dkrClient.ContainerList(context.TODO(), dkrcont.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("volume", vol.Name)),
+	})
+	if err != nil {
+		return bosherr.WrapError(err, "Listing containers using volume")
+	}
+
+	if len(containers) > 0 {
+		return ErrNotCopyable
+	}
+
+	return nil
+}
+
+func (f Factory) cleanUpDisk(disk Disk) {
+	err := disk.Delete()
+	if err != nil {
+		f.logger.Error(f.logTag, "Failed cleaning up disk '%s': %s", disk.ID(), err)
+	}
 }
 
 func (f Factory) Find(id apiv1.DiskCID) (Disk, error) {
-	return NewVolume(id, f.dkrClient, f.logger), nil
+	return NewVolume(id, f.dkrClient, f.loopFiles, f.metaStore, f.logger), nil
+}
+
+// List returns persistent disks along with metadata set by the Director.
+func (f Factory) List() ([]Summary, error) {
+	metas, err := f.metaStore.List()
+	if err != nil {
+		return nil, err
+	}
+
+	diskIDs, err := f.persistentDiskIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var disks []Summary
+
+	for _, id := range diskIDs {
+		disks = append(disks, Summary{ID: id, Metadata: metas[id]})
+	}
+
+	return disks, nil
+}
+
+// PruneMetadata deletes metadata of volumes that were removed outside of the
+// CPI. Metadata is listed before volumes: records are only saved for existing
+// volumes, so a record without a volume listed afterwards is stale even while
+// other disks are being created.
+func (f Factory) PruneMetadata() ([]apiv1.DiskCID, error) {
+	metas, err := f.metaStore.List()
+	if err != nil {
+		return nil, err
+	}
+
+	diskIDs, err := f.persistentDiskIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range diskIDs {
+		delete(metas, id)
+	}
+
+	var pruned []apiv1.DiskCID
+
+	for id := range metas {
+		f.logger.Debug(f.logTag, "Deleting metadata of missing disk '%s'", id)
+
+		err := f.metaStore.Delete(id)
+		if err != nil {
+			return nil, err
+		}
+
+		pruned = append(pruned, id)
+	}
+
+	sort.Slice(pruned, func(i, j int) bool { return pruned[i].AsString() < pruned[j].AsString() })
+
+	return pruned, nil
+}
+
+func (f Factory) persistentDiskIDs() ([]apiv1.DiskCID, error) {
+	resp, err := f.dkrClient.VolumeList(context.TODO(), dkrvoltypes.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", "vol-")),
+	})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing volumes")
+	}
+
+	var diskIDs []apiv1.DiskCID
+
+	for _, vol := range resp.Volumes {
+		// Name filter matches substrings; ephemeral disks belong to VMs
+		if !strings.HasPrefix(vol.Name, "vol-") || strings.HasPrefix(vol.Name, "vol-eph-") {
+			continue
+		}
+
+		diskIDs = append(diskIDs, apiv1.NewDiskCID(vol.Name))
+	}
+
+	return diskIDs, nil
 }
 
 func (f Factory) possiblyFindNodeWithContainer(vmCID apiv1.VMCID) (string, error) {
@@ -90,6 +332,34 @@ func (f Factory) possiblyFindNodeWithContainer(vmCID apiv1.VMCID) (string, error
 	return resp.Node.Name, nil
 }
 
+func (f Factory) copyContents(from, to Disk) error {
+	cmd := host.Cmd{
+		Script: "cp -a /from/. /to/",
+		Binds:  []string{from.ID().AsString() + ":/from:ro", to.ID().AsString() + ":/to"},
+	}
+
+	_, err := f.runner.Run(cmd)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Copying contents of disk '%s' to '%s'", from.ID(), to.ID())
+	}
+
+	return nil
+}
+
+func sameLabels(current, desired map[string]string) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+
+	for key, val := range desired {
+		if currentVal, found := current[key]; !found || currentVal != val {
+			return false
+		}
+	}
+
+	return true
+}
+
 type containerResp struct {
 	Node nodeResp
 }
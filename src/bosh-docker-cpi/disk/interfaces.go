@@ -9,7 +9,7 @@ import (
 //counterfeiter:generate . Creator
 
 type Creator interface {
-	Create(int, *apiv1.VMCID) (Disk, error)
+	Create(int, apiv1.DiskCloudProps, *apiv1.VMCID) (Disk, error)
 }
 
 //counterfeiter:generate . Finder
@@ -18,11 +18,40 @@ type Finder interface {
 	Find(apiv1.DiskCID) (Disk, error)
 }
 
+//counterfeiter:generate . Lister
+
+type Lister interface {
+	List() ([]Summary, error)
+}
+
+//counterfeiter:generate . MetadataPruner
+
+// MetadataPruner removes metadata of disks that were removed outside of the CPI.
+type MetadataPruner interface {
+	PruneMetadata() ([]apiv1.DiskCID, error)
+}
+
+//counterfeiter:generate . Updater
+
+type Updater interface {
+	Update(Disk, int, apiv1.DiskCloudProps) (Disk, error)
+}
+
+// Summary describes a listed disk along with its metadata,
+// which is nil when the Director has not set any.
+type Summary struct {
+	ID       apiv1.DiskCID
+	Metadata map[string]string
+}
+
 //counterfeiter:generate . Disk
 
 type Disk interface {
 	ID() apiv1.DiskCID
 
+	Resize(int) error
+	SetMetadata(apiv1.DiskMeta) error
+
 	Delete() error
 	Exists() (bool, error)
 }
@@ -0,0 +1,138 @@
+package disk
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-docker-cpi/host"
+)
+
+const loopFileExt = ".img"
+
+// LoopFiles back persistent disks with sparse ext4 formatted files on the Docker host
+// so that a disk cannot grow past its requested size.
+type LoopFiles struct {
+	dir    string
+	runner host.Runner
+}
+
+func NewLoopFiles(dir string, runner host.Runner) LoopFiles {
+	return LoopFiles{dir: dir, runner: runner}
+}
+
+func (f LoopFiles) Path(id apiv1.DiskCID) string {
+	return filepath.Join(f.dir, id.AsString()+loopFileExt)
+}
+
+// Create allocates and formats a loop file of size MB, and returns
+// local volume driver options that mount it.
+func (f LoopFiles) Create(id apiv1.DiskCID, size int) (map[string]string, error) {
+	if size <= 0 {
+		return nil, bosherr.Errorf("Expected disk size to be positive, got '%d'", size)
+	}
+
+	path := f.Path(id)
+
+	cmd := host.Cmd{
+		Script: `set -e
+mkdir -p "$(dirname "$FILE")"
+truncate -s "${SIZE}M" "$FILE"
+mkfs.ext4 -q -F "$FILE" || { rm -f "$FILE"; exit 1; }`,
+		Env:   []string{"FILE=" + path, "SIZE=" + strconv.Itoa(size)},
+		Binds: []string{f.dir + ":" + f.dir},
+	}
+
+	_, err := f.runner.Run(cmd)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Creating loop file '%s'", path)
+	}
+
+	return map[string]string{"type": "ext4", "o": "loop", "device": path}, nil
+}
+
+// Size returns the size of a loop file in MB.
+func (f LoopFiles) Size(path string) (int, error) {
+	dir := filepath.Dir(path)
+
+	cmd := host.Cmd{
+		Script: `stat -c %s "$FILE"`,
+		Env:    []string{"FILE=" + path},
+		Binds:  []string{dir + ":" + dir},
+	}
+
+	out, err := f.runner.Run(cmd)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Checking size of loop file '%s'", path)
+	}
+
+	bytes, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, bosherr.WrapErrorf(err, "Parsing size of loop file '%s'", path)
+	}
+
+	return int(bytes / (1024 * 1024)), nil
+}
+
+// Grow extends a loop file and its file system to size MB.
+// The file system must not be mounted.
+func (f LoopFiles) Grow(path string, size int) error {
+	dir := filepath.Dir(path)
+
+	cmd := host.Cmd{
+		Script: `set -e
+e2fsck -f -y "$FILE" || [ $? -le 1 ]
+truncate -s "${SIZE}M" "$FILE"
+resize2fs "$FILE"`,
+		Env:   []string{"FILE=" + path, "SIZE=" + strconv.Itoa(size)},
+		Binds: []string{dir + ":" + dir},
+	}
+
+	_, err := f.runner.Run(cmd)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Growing loop file '%s'", path)
+	}
+
+	return nil
+}
+
+func (f LoopFiles) Delete(path string) error {
+	dir := filepath.Dir(path)
+
+	cmd := host.Cmd{
+		Script: `rm -f "$FILE"`,
+		Env:    []string{"FILE=" + path},
+		Binds:  []string{dir + ":" + dir},
+	}
+
+	_, err := f.runner.Run(cmd)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting loop file '%s'", path)
+	}
+
+	return nil
+}
+
+// BackingFile returns the loop file of a volume with driver options opts,
+// if it was created with LoopFiles.
+func (f LoopFiles) BackingFile(id apiv1.DiskCID, opts map[string]string) (string, bool) {
+	return loopFilePath(id, opts)
+}
+
+// loopFilePath returns the backing file of a volume created with LoopFiles.
+// The file name is checked against the volume name so that volumes created
+// by operators with o=loop are never cleaned up.
+func loopFilePath(id apiv1.DiskCID, opts map[string]string) (string, bool) {
+	if opts["o"] != "loop" || !filepath.IsAbs(opts["device"]) {
+		return "", false
+	}
+
+	if filepath.Base(opts["device"]) != id.AsString()+loopFileExt {
+		return "", false
+	}
+
+	return opts["device"], true
+}
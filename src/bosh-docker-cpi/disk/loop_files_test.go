@@ -0,0 +1,111 @@
+package disk_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "bosh-docker-cpi/disk"
+	"bosh-docker-cpi/host/hostfakes"
+)
+
+var _ = Describe("LoopFiles", func() {
+	var (
+		runner    *hostfakes.FakeRunner
+		loopFiles LoopFiles
+	)
+
+	BeforeEach(func() {
+		runner = &hostfakes.FakeRunner{}
+		loopFiles = NewLoopFiles("/var/lib/disks", runner)
+	})
+
+	Describe("Create", func() {
+		It("formats a sparse file of the requested size and returns loop mount options", func() {
+			opts, err := loopFiles.Create(apiv1.NewDiskCID("vol-123"), 1024)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(opts).To(Equal(map[string]string{
+				"type":   "ext4",
+				"o":      "loop",
+				"device": "/var/lib/disks/vol-123.img",
+			}))
+
+			Expect(runner.RunCallCount()).To(Equal(1))
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Script).To(ContainSubstring(`truncate -s "${SIZE}M" "$FILE"`))
+			Expect(cmd.Script).To(ContainSubstring(`mkfs.ext4`))
+			Expect(cmd.Env).To(ConsistOf("FILE=/var/lib/disks/vol-123.img", "SIZE=1024"))
+			Expect(cmd.Binds).To(Equal([]string{"/var/lib/disks:/var/lib/disks"}))
+			Expect(cmd.Privileged).To(BeFalse())
+		})
+
+		It("returns error if size is not positive", func() {
+			_, err := loopFiles.Create(apiv1.NewDiskCID("vol-123"), 0)
+			Expect(err).To(MatchError(ContainSubstring("Expected disk size to be positive")))
+			Expect(runner.RunCallCount()).To(Equal(0))
+		})
+
+		It("returns error if formatting fails", func() {
+			runner.RunReturns(nil, errors.New("fake-err"))
+
+			_, err := loopFiles.Create(apiv1.NewDiskCID("vol-123"), 1024)
+			Expect(err).To(MatchError(ContainSubstring("fake-err")))
+		})
+	})
+
+	Describe("Size", func() {
+		It("returns the file size in MB", func() {
+			runner.RunReturns([]byte("2147483648\n"), nil)
+
+			size, err := loopFiles.Size("/var/lib/disks/vol-123.img")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(size).To(Equal(2048))
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Script).To(ContainSubstring("stat"))
+			Expect(cmd.Env).To(Equal([]string{"FILE=/var/lib/disks/vol-123.img"}))
+		})
+
+		It("returns error if output is not a number", func() {
+			runner.RunReturns([]byte("nope"), nil)
+
+			_, err := loopFiles.Size("/var/lib/disks/vol-123.img")
+			Expect(err).To(MatchError(ContainSubstring("Parsing size of loop file")))
+		})
+	})
+
+	Describe("Grow", func() {
+		It("checks the file system before extending the file and file system", func() {
+			err := loopFiles.Grow("/var/lib/disks/vol-123.img", 4096)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Script).To(MatchRegexp(`(?s)e2fsck -f -y.*truncate -s "\$\{SIZE\}M".*resize2fs`))
+			Expect(cmd.Env).To(ConsistOf("FILE=/var/lib/disks/vol-123.img", "SIZE=4096"))
+			Expect(cmd.Binds).To(Equal([]string{"/var/lib/disks:/var/lib/disks"}))
+			Expect(cmd.Privileged).To(BeFalse())
+		})
+
+		It("returns error if growing fails", func() {
+			runner.RunReturns(nil, errors.New("fake-err"))
+
+			err := loopFiles.Grow("/var/lib/disks/vol-123.img", 4096)
+			Expect(err).To(MatchError(ContainSubstring("fake-err")))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("removes the file", func() {
+			err := loopFiles.Delete("/var/lib/disks/vol-123.img")
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Script).To(Equal(`rm -f "$FILE"`))
+			Expect(cmd.Env).To(Equal([]string{"FILE=/var/lib/disks/vol-123.img"}))
+			Expect(cmd.Binds).To(Equal([]string{"/var/lib/disks:/var/lib/disks"}))
+		})
+	})
+})
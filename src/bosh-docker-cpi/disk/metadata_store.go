@@ -0,0 +1,65 @@
+package disk
+
+import (
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-docker-cpi/host"
+)
+
+// MetadataStore keeps disk metadata in records named after disk CIDs
+// on the Docker host, since volumes cannot be relabeled.
+// All operations are no-ops when no directory is configured.
+type MetadataStore struct {
+	records host.RecordStore
+}
+
+func NewMetadataStore(dir string, runner host.Runner) MetadataStore {
+	return MetadataStore{records: host.NewRecordStore(dir, runner)}
+}
+
+func (s MetadataStore) Enabled() bool { return s.records.Enabled() }
+
+func (s MetadataStore) Save(id apiv1.DiskCID, meta apiv1.DiskMeta) error {
+	err := s.records.Save(id.AsString(), meta)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Saving metadata of disk '%s'", id.AsString())
+	}
+
+	return nil
+}
+
+// Move hands metadata over to a disk that replaced the given disk.
+func (s MetadataStore) Move(from, to apiv1.DiskCID) error {
+	err := s.records.Move(from.AsString(), to.AsString())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Moving metadata of disk '%s'", from.AsString())
+	}
+
+	return nil
+}
+
+func (s MetadataStore) Delete(id apiv1.DiskCID) error {
+	err := s.records.Delete(id.AsString())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting metadata of disk '%s'", id.AsString())
+	}
+
+	return nil
+}
+
+// List returns metadata of all disks keyed by disk CID.
+func (s MetadataStore) List() (map[apiv1.DiskCID]map[string]string, error) {
+	records, err := s.records.List()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing disk metadata")
+	}
+
+	metas := map[apiv1.DiskCID]map[string]string{}
+
+	for key, meta := range records {
+		metas[apiv1.NewDiskCID(key)] = meta
+	}
+
+	return metas, nil
+}
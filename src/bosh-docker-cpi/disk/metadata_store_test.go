@@ -0,0 +1,86 @@
+package disk_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "bosh-docker-cpi/disk"
+	"bosh-docker-cpi/host/hostfakes"
+)
+
+var _ = Describe("MetadataStore", func() {
+	var (
+		runner *hostfakes.FakeRunner
+		store  MetadataStore
+	)
+
+	BeforeEach(func() {
+		runner = &hostfakes.FakeRunner{}
+		store = NewMetadataStore("/var/lib/disk-metadata", runner)
+	})
+
+	Describe("Save", func() {
+		It("writes metadata named after the disk", func() {
+			meta := apiv1.NewDiskMeta(map[string]interface{}{"deployment": "cf", "instance_index": 0})
+
+			err := store.Save(apiv1.NewDiskCID("vol-123"), meta)
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Env).To(ConsistOf(
+				"FILE=/var/lib/disk-metadata/vol-123.json",
+				`RECORD={"deployment":"cf","instance_index":0}`,
+			))
+		})
+
+		It("returns error if writing fails", func() {
+			runner.RunReturns(nil, errors.New("fake-err"))
+
+			err := store.Save(apiv1.NewDiskCID("vol-123"), apiv1.DiskMeta{})
+			Expect(err).To(MatchError(ContainSubstring("Saving metadata of disk 'vol-123'")))
+			Expect(err).To(MatchError(ContainSubstring("fake-err")))
+		})
+	})
+
+	Describe("List", func() {
+		It("returns stringified metadata keyed by disk CID", func() {
+			runner.RunReturns([]byte(
+				"vol-123\t{\"deployment\":\"cf\",\"instance_index\":0}\n"+
+					"vol-456\t{\"deployment\":\"db\"}\n",
+			), nil)
+
+			metas, err := store.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metas).To(Equal(map[apiv1.DiskCID]map[string]string{
+				apiv1.NewDiskCID("vol-123"): {"deployment": "cf", "instance_index": "0"},
+				apiv1.NewDiskCID("vol-456"): {"deployment": "db"},
+			}))
+		})
+	})
+
+	Describe("Move", func() {
+		It("renames the record of the replaced disk", func() {
+			err := store.Move(apiv1.NewDiskCID("vol-old"), apiv1.NewDiskCID("vol-new"))
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Env).To(ConsistOf(
+				"FROM=/var/lib/disk-metadata/vol-old.json",
+				"TO=/var/lib/disk-metadata/vol-new.json",
+			))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("removes the record of the disk", func() {
+			err := store.Delete(apiv1.NewDiskCID("vol-123"))
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Env).To(Equal([]string{"FILE=/var/lib/disk-metadata/vol-123.json"}))
+		})
+	})
+})
@@ -0,0 +1,103 @@
+package disk
+
+import (
+	"sort"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+const defaultDriver = "local"
+
+// localDriverOpts are the options accepted by Docker's local volume driver;
+// they map to mount(8) arguments: type=tmpfs,device=tmpfs,o=size=100m or
+// type=none,o=bind,device=/host/path.
+var localDriverOpts = []string{"device", "o", "type"}
+
+type Props struct {
+	Driver     string            `json:"driver"`
+	DriverOpts map[string]string `json:"driver_opts"`
+	Labels     map[string]string `json:"labels"`
+
+	// EnforceSize overrides the global disks.enforce_size setting
+	EnforceSize *bool `json:"enforce_size"`
+}
+
+func (p Props) DriverOrDefault() string {
+	if len(p.Driver) == 0 {
+		return defaultDriver
+	}
+
+	return p.Driver
+}
+
+// EnforcesSize reports whether the disk should be backed by a loop file.
+// Disks with a custom driver or driver options are left to their driver.
+func (p Props) EnforcesSize(def bool) bool {
+	if p.EnforceSize != nil {
+		return *p.EnforceSize
+	}
+
+	return def && p.DriverOrDefault() == defaultDriver && len(p.DriverOpts) == 0
+}
+
+func (p Props) Validate() error {
+	if strings.ContainsAny(p.Driver, " \t\n") {
+		return bosherr.Errorf("Expected 'driver' to not contain whitespace, got '%s'", p.Driver)
+	}
+
+	for key := range p.Labels {
+		if len(strings.TrimSpace(key)) == 0 {
+			return bosherr.Error("Expected 'labels' to not contain empty keys")
+		}
+	}
+
+	if p.EnforceSize != nil && *p.EnforceSize {
+		if p.DriverOrDefault() != defaultDriver || len(p.DriverOpts) > 0 {
+			return bosherr.Error("Expected 'enforce_size' to not be combined with 'driver' or 'driver_opts'")
+		}
+	}
+
+	if p.DriverOrDefault() == defaultDriver {
+		return p.validateLocalDriverOpts()
+	}
+
+	for key := range p.DriverOpts {
+		if len(strings.TrimSpace(key)) == 0 {
+			return bosherr.Error("Expected 'driver_opts' to not contain empty keys")
+		}
+	}
+
+	return nil
+}
+
+func (p Props) validateLocalDriverOpts() error {
+	if len(p.DriverOpts) == 0 {
+		return nil
+	}
+
+	var unknownKeys []string
+
+	for key := range p.DriverOpts {
+		idx := sort.SearchStrings(localDriverOpts, key)
+		if idx == len(localDriverOpts) || localDriverOpts[idx] != key {
+			unknownKeys = append(unknownKeys, key)
+		}
+	}
+
+	if len(unknownKeys) > 0 {
+		sort.Strings(unknownKeys)
+		return bosherr.Errorf("Expected 'driver_opts' for the local driver to only include %s, got unknown '%s'",
+			strings.Join(localDriverOpts, ", "), strings.Join(unknownKeys, "', '"))
+	}
+
+	if len(p.DriverOpts["type"]) == 0 {
+		return bosherr.Error("Expected 'driver_opts' for the local driver to specify 'type' (e.g. 'tmpfs', 'ext4' or 'none')")
+	}
+
+	if len(p.DriverOpts["device"]) == 0 {
+		return bosherr.Error("Expected 'driver_opts' for the local driver to specify 'device' (e.g. 'tmpfs' or a host path)")
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package disk_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "bosh-docker-cpi/disk"
+)
+
+var _ = Describe("Props", func() {
+	Describe("Unmarshal", func() {
+		It("picks up driver, driver options and labels", func() {
+			var props Props
+
+			err := json.Unmarshal([]byte(`{
+				"driver": "local",
+				"driver_opts": {"type": "tmpfs", "device": "tmpfs", "o": "size=100m"},
+				"labels": {"team": "db"}
+			}`), &props)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(props.Driver).To(Equal("local"))
+			Expect(props.DriverOpts).To(Equal(map[string]string{"type": "tmpfs", "device": "tmpfs", "o": "size=100m"}))
+			Expect(props.Labels).To(Equal(map[string]string{"team": "db"}))
+		})
+	})
+
+	Describe("DriverOrDefault", func() {
+		It("defaults to the local driver", func() {
+			Expect(Props{}.DriverOrDefault()).To(Equal("local"))
+			Expect(Props{Driver: "rexray"}.DriverOrDefault()).To(Equal("rexray"))
+		})
+	})
+
+	Describe("EnforcesSize", func() {
+		enabled, disabled := true, false
+
+		It("falls back to the global setting", func() {
+			Expect(Props{}.EnforcesSize(true)).To(BeTrue())
+			Expect(Props{}.EnforcesSize(false)).To(BeFalse())
+		})
+
+		It("prefers the disk setting", func() {
+			Expect(Props{EnforceSize: &enabled}.EnforcesSize(false)).To(BeTrue())
+			Expect(Props{EnforceSize: &disabled}.EnforcesSize(true)).To(BeFalse())
+		})
+
+		It("leaves custom volumes to their driver", func() {
+			Expect(Props{Driver: "rexray/ebs"}.EnforcesSize(true)).To(BeFalse())
+
+			tmpfs := Props{DriverOpts: map[string]string{"type": "tmpfs", "device": "tmpfs"}}
+			Expect(tmpfs.EnforcesSize(true)).To(BeFalse())
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts empty props", func() {
+			Expect(Props{}.Validate()).To(Succeed())
+		})
+
+		It("accepts tmpfs and bind mount options for the local driver", func() {
+			tmpfs := Props{DriverOpts: map[string]string{"type": "tmpfs", "device": "tmpfs", "o": "size=1g"}}
+			Expect(tmpfs.Validate()).To(Succeed())
+
+			bind := Props{DriverOpts: map[string]string{"type": "none", "device": "/data", "o": "bind"}}
+			Expect(bind.Validate()).To(Succeed())
+		})
+
+		It("rejects unknown local driver options", func() {
+			props := Props{DriverOpts: map[string]string{"type": "tmpfs", "device": "tmpfs", "size": "1g"}}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("unknown 'size'")))
+		})
+
+		It("requires type and device for local driver options", func() {
+			noType := Props{DriverOpts: map[string]string{"device": "tmpfs"}}
+			Expect(noType.Validate()).To(MatchError(ContainSubstring("'type'")))
+
+			noDevice := Props{DriverOpts: map[string]string{"type": "tmpfs"}}
+			Expect(noDevice.Validate()).To(MatchError(ContainSubstring("'device'")))
+		})
+
+		It("passes through options for other drivers", func() {
+			props := Props{Driver: "rexray/ebs", DriverOpts: map[string]string{"size": "10"}}
+			Expect(props.Validate()).To(Succeed())
+		})
+
+		It("rejects drivers containing whitespace", func() {
+			Expect(Props{Driver: "lo cal"}.Validate()).To(MatchError(ContainSubstring("whitespace")))
+		})
+
+		It("rejects enforcing size of custom volumes", func() {
+			enabled := true
+
+			props := Props{EnforceSize: &enabled, DriverOpts: map[string]string{"type": "tmpfs", "device": "tmpfs"}}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("'enforce_size'")))
+
+			props = Props{EnforceSize: &enabled, Driver: "rexray/ebs"}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("'enforce_size'")))
+		})
+
+		It("rejects empty label keys", func() {
+			props := Props{Labels: map[string]string{" ": "x"}}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("empty keys")))
+		})
+	})
+})
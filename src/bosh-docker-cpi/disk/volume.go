@@ -2,24 +2,44 @@ package disk
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	cerrdefs "github.com/containerd/errdefs"
+	dkrcont "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	dkrclient "github.com/docker/docker/client"
 )
 
+// ErrNotResizable is returned when a disk cannot be resized in place,
+// e.g. because its size is not enforced or it would need to shrink.
+var ErrNotResizable = errors.New("Disk cannot be resized in place")
+
+// ErrNotCopyable is returned when a disk cannot be replaced by a copy,
+// e.g. because a volume plugin manages it or a container is using it.
+var ErrNotCopyable = errors.New("Disk contents cannot be copied")
+
 type Volume struct {
 	id apiv1.DiskCID
 
 	dkrClient *dkrclient.Client
+	loopFiles LoopFiles
+	metaStore MetadataStore
 
 	logger boshlog.Logger
 }
 
-func NewVolume(id apiv1.DiskCID, dkrClient *dkrclient.Client, logger boshlog.Logger) Volume {
-	return Volume{id: id, dkrClient: dkrClient, logger: logger}
+func NewVolume(
+	id apiv1.DiskCID,
+	dkrClient *dkrclient.Client,
+	loopFiles LoopFiles,
+	metaStore MetadataStore,
+	logger boshlog.Logger,
+) Volume {
+	return Volume{id: id, dkrClient: dkrClient, loopFiles: loopFiles, metaStore: metaStore, logger: logger}
 }
 
 func (s Volume) ID() apiv1.DiskCID { return s.id }
@@ -27,12 +47,108 @@ func (s Volume) ID() apiv1.DiskCID { return s.id }
 func (s Volume) Delete() error {
 	s.logger.Debug("Volume", "Deleting disk '%s'", s.id)
 
-	err := s.dkrClient.VolumeRemove(context.TODO(), s.id.AsString(), true)
+	var loopFile string
+
+	vol, err := s.dkrClient.VolumeInspect(context.TODO(), s.id.AsString())
+	if err != nil {
+		if !cerrdefs.IsNotFound(err) {
+			return bosherr.WrapError(err, "Finding volume")
+		}
+	} else {
+		loopFile, _ = loopFilePath(s.id, vol.Options)
+	}
+
+	err = s.checkUnused()
+	if err != nil {
+		return err
+	}
+
+	err = s.dkrClient.VolumeRemove(context.TODO(), s.id.AsString(), true)
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Deleting volume")
 	}
 
-	return nil
+	if len(loopFile) > 0 {
+		err = s.loopFiles.Delete(loopFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.metaStore.Delete(s.id)
+}
+
+// checkUnused refuses to delete volumes that containers still reference,
+// including holder containers of volumes hot attached to running VMs
+// which Docker itself does not see as mounted.
+func (s Volume) checkUnused() error {
+	listOpts := dkrcont.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("volume", s.id.AsString())),
+	}
+
+	containers, err := s.dkrClient.ContainerList(context.TODO(), listOpts)
+	if err != nil {
+		return bosherr.WrapError(err, "Listing containers using volume")
+	}
+
+	if len(containers) == 0 {
+		return nil
+	}
+
+	user := containers[0].ID
+	if len(containers[0].Names) > 0 {
+		user = strings.TrimPrefix(containers[0].Names[0], "/")
+	}
+
+	return bosherr.Errorf("Expected disk '%s' to be detached, but container '%s' uses it", s.id.AsString(), user)
+}
+
+func (s Volume) SetMetadata(meta apiv1.DiskMeta) error {
+	if !s.metaStore.Enabled() {
+		return nil
+	}
+
+	exists, err := s.Exists()
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return bosherr.Errorf("Expected disk '%s' to exist", s.id.AsString())
+	}
+
+	return s.metaStore.Save(s.id, meta)
+}
+
+// Resize grows the loop file backing the volume to size MB.
+// Volumes must not be in use by containers while resizing.
+func (s Volume) Resize(size int) error {
+	s.logger.Debug("Volume", "Resizing disk '%s' to '%d'", s.id, size)
+
+	vol, err := s.dkrClient.VolumeInspect(context.TODO(), s.id.AsString())
+	if err != nil {
+		return bosherr.WrapError(err, "Finding volume")
+	}
+
+	loopFile, found := loopFilePath(s.id, vol.Options)
+	if !found {
+		return ErrNotResizable
+	}
+
+	currentSize, err := s.loopFiles.Size(loopFile)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case size == currentSize:
+		return nil
+	case size < currentSize:
+		return ErrNotResizable
+	default:
+		return s.loopFiles.Grow(loopFile, size)
+	}
 }
 
 func (s Volume) Exists() (bool, error) {
@@ -12,8 +12,15 @@ var _ = Describe("Volume", func() {
 	Describe("ID", func() {
 		It("returns the disk CID it was created with", func() {
 			diskCID := apiv1.NewDiskCID("vol-abc123")
-			vol := NewVolume(diskCID, nil, nil)
+			vol := NewVolume(diskCID, nil, LoopFiles{}, MetadataStore{}, nil)
 			Expect(vol.ID()).To(Equal(diskCID))
 		})
 	})
+
+	Describe("SetMetadata", func() {
+		It("discards metadata without touching the volume when no metadata dir is configured", func() {
+			vol := NewVolume(apiv1.NewDiskCID("vol-abc123"), nil, LoopFiles{}, MetadataStore{}, nil)
+			Expect(vol.SetMetadata(apiv1.NewDiskMeta(map[string]interface{}{"deployment": "cf"}))).To(Succeed())
+		})
+	})
 })
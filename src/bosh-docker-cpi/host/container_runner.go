@@ -0,0 +1,163 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	cerrdefs "github.com/containerd/errdefs"
+	dkrcont "github.com/docker/docker/api/types/container"
+	dkrimages "github.com/docker/docker/api/types/image"
+	dkrstrslice "github.com/docker/docker/api/types/strslice"
+	dkrclient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// DefaultImage includes bash, coreutils, util-linux (nsenter, mount),
+// e2fsprogs and tar which helper scripts rely on.
+const DefaultImage = "ubuntu:noble"
+
+// ContainerRunner runs each command in a short-lived helper container
+// that is removed once the command exits. Containers are only privileged
+// when the command asks for it.
+type ContainerRunner struct {
+	dkrClient *dkrclient.Client
+	image     string
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewContainerRunner(dkrClient *dkrclient.Client, image string, logger boshlog.Logger) ContainerRunner {
+	if len(image) == 0 {
+		image = DefaultImage
+	}
+
+	return ContainerRunner{
+		dkrClient: dkrClient,
+		image:     image,
+
+		logTag: "host.ContainerRunner",
+		logger: logger,
+	}
+}
+
+// Run returns the script's stdout, or an error including its stderr
+// when it exits with a non-zero status.
+func (r ContainerRunner) Run(cmd Cmd) ([]byte, error) {
+	err := r.ensureImage()
+	if err != nil {
+		return nil, err
+	}
+
+	containerConfig := &dkrcont.Config{
+		Image: r.image,
+		Cmd:   dkrstrslice.StrSlice{"bash", "-c", cmd.Script},
+		Env:   cmd.Env,
+	}
+
+	hostConfig := &dkrcont.HostConfig{
+		Privileged: cmd.Privileged,
+		Binds:      cmd.Binds,
+	}
+
+	if cmd.PIDHost {
+		hostConfig.PidMode = "host"
+	}
+
+	r.logger.Debug(r.logTag, "Running helper script %q with binds %v", cmd.Script, cmd.Binds)
+
+	resp, err := r.dkrClient.ContainerCreate(context.TODO(), containerConfig, hostConfig, nil, nil, "")
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Creating helper container")
+	}
+
+	defer func() {
+		rmErr := r.dkrClient.ContainerRemove(context.TODO(), resp.ID, dkrcont.RemoveOptions{Force: true})
+		if rmErr != nil {
+			r.logger.Error(r.logTag, "Failed removing helper container '%s': %s", resp.ID, rmErr)
+		}
+	}()
+
+	waitCh, waitErrCh := r.dkrClient.ContainerWait(context.TODO(), resp.ID, dkrcont.WaitConditionNextExit)
+
+	err = r.dkrClient.ContainerStart(context.TODO(), resp.ID, dkrcont.StartOptions{})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Starting helper container")
+	}
+
+	var statusCode int64
+
+	select {
+	case waitResp := <-waitCh:
+		if waitResp.Error != nil {
+			return nil, bosherr.Errorf("Waiting for helper container: %s", waitResp.Error.Message)
+		}
+		statusCode = waitResp.StatusCode
+	case err := <-waitErrCh:
+		return nil, bosherr.WrapError(err, "Waiting for helper container")
+	}
+
+	logs, err := r.dkrClient.ContainerLogs(context.TODO(), resp.ID, dkrcont.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading helper container output")
+	}
+
+	defer logs.Close() //nolint:errcheck
+
+	var stdout, stderr bytes.Buffer
+
+	_, err = stdcopy.StdCopy(&stdout, &stderr, logs)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Demultiplexing helper container output")
+	}
+
+	if statusCode != 0 {
+		return nil, bosherr.Errorf("Helper script failed [exit status %d]: %s", statusCode, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func (r ContainerRunner) ensureImage() error {
+	_, err := r.dkrClient.ImageInspect(context.TODO(), r.image)
+	if err == nil {
+		return nil
+	}
+
+	if !cerrdefs.IsNotFound(err) {
+		return bosherr.WrapErrorf(err, "Inspecting helper image '%s'", r.image)
+	}
+
+	r.logger.Debug(r.logTag, "Pulling helper image '%s'", r.image)
+
+	responseBody, err := r.dkrClient.ImagePull(context.TODO(), r.image, dkrimages.PullOptions{})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Pulling helper image '%s'", r.image)
+	}
+
+	defer responseBody.Close() //nolint:errcheck
+
+	decoder := json.NewDecoder(responseBody)
+
+	for {
+		var event struct {
+			Error string `json:"error"`
+		}
+
+		err := decoder.Decode(&event)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return bosherr.WrapError(err, "Reading pull response")
+		}
+
+		if event.Error != "" {
+			return bosherr.Errorf("Pulling helper image '%s': %s", r.image, event.Error)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package host
+
+//go:generate go tool counterfeiter -generate
+
+//counterfeiter:generate . Runner
+
+// Runner executes commands on the Docker host rather than where the CPI runs,
+// which matters when the CPI talks to a remote Docker daemon.
+type Runner interface {
+	Run(Cmd) ([]byte, error)
+}
+
+var _ Runner = ContainerRunner{}
+
+// Cmd is a bash script run by a Runner.
+type Cmd struct {
+	Script string
+	Env    []string
+
+	// Binds are host paths (or volumes) made available to the script
+	Binds []string
+
+	// PIDHost runs the script in the host PID namespace so that it can
+	// nsenter into the host or other containers
+	PIDHost bool
+
+	// Privileged runs the script with all capabilities and devices,
+	// e.g. to mount file systems on the Docker host
+	Privileged bool
+}
@@ -0,0 +1,193 @@
+package host
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// RecordStore keeps JSON records named after their keys in a directory on
+// the Docker host, e.g. metadata of objects Docker cannot relabel. Records
+// are written through unprivileged helper containers. All operations are
+// no-ops when no directory is configured.
+type RecordStore struct {
+	dir    string
+	runner Runner
+}
+
+func NewRecordStore(dir string, runner Runner) RecordStore {
+	return RecordStore{dir: dir, runner: runner}
+}
+
+func (s RecordStore) Enabled() bool { return len(s.dir) > 0 }
+
+func (s RecordStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// Save atomically replaces the record with the JSON encoding of val.
+func (s RecordStore) Save(key string, val interface{}) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	valBytes, err := json.Marshal(val)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Marshaling record '%s'", key)
+	}
+
+	cmd := Cmd{
+		Script: `set -e
+mkdir -p "$(dirname "$FILE")"
+printf '%s' "$RECORD" > "$FILE.tmp"
+mv "$FILE.tmp" "$FILE"`,
+		Env:   []string{"FILE=" + s.path(key), "RECORD=" + string(valBytes)},
+		Binds: []string{s.dir + ":" + s.dir},
+	}
+
+	_, err = s.runner.Run(cmd)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Saving record '%s'", key)
+	}
+
+	return nil
+}
+
+// Move renames a record if it exists.
+func (s RecordStore) Move(from, to string) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	cmd := Cmd{
+		Script: `if [ -e "$FROM" ]; then mv "$FROM" "$TO"; fi`,
+		Env:    []string{"FROM=" + s.path(from), "TO=" + s.path(to)},
+		Binds:  []string{s.dir + ":" + s.dir},
+	}
+
+	_, err := s.runner.Run(cmd)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Moving record '%s'", from)
+	}
+
+	return nil
+}
+
+func (s RecordStore) Delete(key string) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	cmd := Cmd{
+		Script: `rm -f "$FILE"`,
+		Env:    []string{"FILE=" + s.path(key)},
+		Binds:  []string{s.dir + ":" + s.dir},
+	}
+
+	_, err := s.runner.Run(cmd)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting record '%s'", key)
+	}
+
+	return nil
+}
+
+// Get returns the record stored under key, or nil when there is none.
+func (s RecordStore) Get(key string) (map[string]string, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	cmd := Cmd{
+		Script: `[ -e "$FILE" ] || exit 0
+printf '%s\t' "$KEY"
+cat "$FILE"
+echo`,
+		Env:   []string{"FILE=" + s.path(key), "KEY=" + key},
+		Binds: []string{s.dir + ":" + s.dir},
+	}
+
+	out, err := s.runner.Run(cmd)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading record '%s'", key)
+	}
+
+	records, err := parseRecordListing(out)
+	if err != nil {
+		return nil, err
+	}
+
+	return records[key], nil
+}
+
+// List returns all records keyed by their keys, with values of each
+// record's top level keys converted to strings.
+func (s RecordStore) List() (map[string]map[string]string, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	cmd := Cmd{
+		Script: `cd "$DIR" 2>/dev/null || exit 0
+for f in *.json; do
+  [ -e "$f" ] || continue
+  printf '%s\t' "${f%.json}"
+  cat "$f"
+  echo
+done`,
+		Env:   []string{"DIR=" + s.dir},
+		Binds: []string{s.dir + ":" + s.dir},
+	}
+
+	out, err := s.runner.Run(cmd)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing records")
+	}
+
+	return parseRecordListing(out)
+}
+
+func parseRecordListing(out []byte) (map[string]map[string]string, error) {
+	records := map[string]map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		pieces := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(pieces) != 2 {
+			continue
+		}
+
+		var kvs map[string]interface{}
+
+		err := json.Unmarshal([]byte(pieces[1]), &kvs)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Unmarshaling record '%s'", pieces[0])
+		}
+
+		record := map[string]string{}
+
+		for key, val := range kvs {
+			if str, ok := val.(string); ok {
+				record[key] = str
+			} else if val != nil {
+				record[key] = fmt.Sprintf("%v", val)
+			}
+		}
+
+		records[pieces[0]] = record
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading records")
+	}
+
+	return records, nil
+}
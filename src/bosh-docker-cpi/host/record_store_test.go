@@ -0,0 +1,133 @@
+package host_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "bosh-docker-cpi/host"
+	"bosh-docker-cpi/host/hostfakes"
+)
+
+var _ = Describe("RecordStore", func() {
+	var (
+		runner *hostfakes.FakeRunner
+		store  RecordStore
+	)
+
+	BeforeEach(func() {
+		runner = &hostfakes.FakeRunner{}
+		store = NewRecordStore("/var/lib/records", runner)
+	})
+
+	Describe("Save", func() {
+		It("writes the record as JSON named after its key", func() {
+			err := store.Save("rec-123", map[string]interface{}{"deployment": "cf", "index": 0})
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Env).To(ConsistOf(
+				"FILE=/var/lib/records/rec-123.json",
+				`RECORD={"deployment":"cf","index":0}`,
+			))
+			Expect(cmd.Binds).To(Equal([]string{"/var/lib/records:/var/lib/records"}))
+			Expect(cmd.Privileged).To(BeFalse())
+		})
+
+		It("returns error if writing fails", func() {
+			runner.RunReturns(nil, errors.New("fake-err"))
+
+			err := store.Save("rec-123", nil)
+			Expect(err).To(MatchError(ContainSubstring("fake-err")))
+		})
+	})
+
+	Describe("Get", func() {
+		It("returns the stringified record", func() {
+			runner.RunReturns([]byte("rec-123\t{\"deployment\":\"cf\",\"index\":0}\n"), nil)
+
+			record, err := store.Get("rec-123")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record).To(Equal(map[string]string{"deployment": "cf", "index": "0"}))
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Env).To(ConsistOf("FILE=/var/lib/records/rec-123.json", "KEY=rec-123"))
+		})
+
+		It("returns nil when there is no record", func() {
+			record, err := store.Get("rec-123")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record).To(BeNil())
+		})
+	})
+
+	Describe("List", func() {
+		It("returns stringified records keyed by their keys", func() {
+			runner.RunReturns([]byte(
+				"rec-123\t{\"deployment\":\"cf\",\"index\":0}\n"+
+					"rec-456\t{\"deployment\":\"db\"}\n",
+			), nil)
+
+			records, err := store.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(Equal(map[string]map[string]string{
+				"rec-123": {"deployment": "cf", "index": "0"},
+				"rec-456": {"deployment": "db"},
+			}))
+		})
+
+		It("returns error if a record is not valid JSON", func() {
+			runner.RunReturns([]byte("rec-123\t{\n"), nil)
+
+			_, err := store.List()
+			Expect(err).To(MatchError(ContainSubstring("rec-123")))
+		})
+	})
+
+	Describe("Move", func() {
+		It("renames the record", func() {
+			err := store.Move("rec-old", "rec-new")
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Env).To(ConsistOf(
+				"FROM=/var/lib/records/rec-old.json",
+				"TO=/var/lib/records/rec-new.json",
+			))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("force removes the record", func() {
+			err := store.Delete("rec-123")
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Script).To(Equal(`rm -f "$FILE"`))
+			Expect(cmd.Env).To(Equal([]string{"FILE=/var/lib/records/rec-123.json"}))
+		})
+	})
+
+	Context("when no directory is configured", func() {
+		BeforeEach(func() {
+			store = NewRecordStore("", runner)
+		})
+
+		It("does nothing", func() {
+			Expect(store.Save("rec-123", nil)).To(Succeed())
+			Expect(store.Move("rec-123", "rec-456")).To(Succeed())
+			Expect(store.Delete("rec-123")).To(Succeed())
+
+			record, err := store.Get("rec-123")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(record).To(BeNil())
+
+			records, err := store.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(BeEmpty())
+
+			Expect(runner.RunCallCount()).To(Equal(0))
+		})
+	})
+})
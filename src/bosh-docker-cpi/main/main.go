@@ -2,7 +2,10 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/cloudfoundry/bosh-cpi-go/rpc"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
@@ -15,6 +18,10 @@ import (
 
 var (
 	configPathOpt = flag.String("configPath", "", "Path to configuration file")
+	listVMsOpt    = flag.Bool("listVMs", false, "List VMs and their metadata instead of serving a CPI request")
+	listDisksOpt  = flag.Bool("listDisks", false, "List persistent disks and their metadata instead of serving a CPI request")
+	pruneMetaOpt  = flag.Bool("pruneMetadata", false, "Delete metadata of VMs and disks removed outside of the CPI instead of serving a CPI request")
+	filterOpt     = flag.String("filter", "", "Metadata filter used when listing (e.g. 'deployment=cf,job=router,index=0')")
 )
 
 func main() {
@@ -31,6 +38,33 @@ func main() {
 
 	cpiFactory := cpi.NewFactory(fs, uuidGen, cfg.Actions, logger, cfg)
 
+	if *listVMsOpt {
+		err = listVMs(cpiFactory, *filterOpt)
+		if err != nil {
+			logger.Error("main", "Listing VMs %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *listDisksOpt {
+		err = listDisks(cpiFactory, *filterOpt)
+		if err != nil {
+			logger.Error("main", "Listing disks %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *pruneMetaOpt {
+		err = pruneMetadata(cpiFactory)
+		if err != nil {
+			logger.Error("main", "Pruning metadata %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cli := rpc.NewFactory(logger).NewCLI(cpiFactory)
 
 	err = cli.ServeOnce()
@@ -40,6 +74,87 @@ func main() {
 	}
 }
 
+func listVMs(cpiFactory cpi.Factory, filter string) error {
+	method, err := cpiFactory.NewListVMsMethod()
+	if err != nil {
+		return err
+	}
+
+	vms, err := method.ListVMs(filter)
+	if err != nil {
+		return err
+	}
+
+	for _, vm := range vms {
+		fmt.Printf("%s\t%s\n", vm.ID.AsString(), formatMetadata(vm.Metadata))
+	}
+
+	return nil
+}
+
+func listDisks(cpiFactory cpi.Factory, filter string) error {
+	method, err := cpiFactory.NewListDisksMethod()
+	if err != nil {
+		return err
+	}
+
+	disks, err := method.ListDisks(filter)
+	if err != nil {
+		return err
+	}
+
+	for _, disk := range disks {
+		meta := formatMetadata(disk.Metadata)
+		if len(meta) == 0 {
+			// Volumes without metadata are likely orphaned
+			meta = "-"
+		}
+
+		fmt.Printf("%s\t%s\n", disk.ID.AsString(), meta)
+	}
+
+	return nil
+}
+
+func pruneMetadata(cpiFactory cpi.Factory) error {
+	method, err := cpiFactory.NewPruneMetadataMethod()
+	if err != nil {
+		return err
+	}
+
+	vmIDs, err := method.PruneVMMetadata()
+	if err != nil {
+		return err
+	}
+
+	for _, vmID := range vmIDs {
+		fmt.Printf("%s\tpruned\n", vmID.AsString())
+	}
+
+	diskIDs, err := method.PruneDiskMetadata()
+	if err != nil {
+		return err
+	}
+
+	for _, diskID := range diskIDs {
+		fmt.Printf("%s\tpruned\n", diskID.AsString())
+	}
+
+	return nil
+}
+
+func formatMetadata(meta map[string]string) string {
+	var pairs []string
+
+	for key, val := range meta {
+		pairs = append(pairs, key+"="+val)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
 func basicDeps() (boshlog.Logger, boshsys.FileSystem, boshsys.CmdRunner, boshuuid.Generator) {
 	logger := boshlog.NewWriterLogger(boshlog.LevelDebug, os.Stderr)
 	fs := boshsys.NewOsFileSystem(logger)
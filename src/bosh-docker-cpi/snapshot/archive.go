@@ -0,0 +1,58 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+
+	"bosh-docker-cpi/host"
+)
+
+type Archive struct {
+	id  apiv1.SnapshotCID
+	dir string
+
+	runner host.Runner
+
+	logger boshlog.Logger
+}
+
+func NewArchive(id apiv1.SnapshotCID, dir string, runner host.Runner, logger boshlog.Logger) Archive {
+	return Archive{id: id, dir: dir, runner: runner, logger: logger}
+}
+
+func (a Archive) ID() apiv1.SnapshotCID { return a.id }
+
+func (a Archive) ArchivePath() string {
+	return filepath.Join(a.dir, a.id.AsString()+".tgz")
+}
+
+func (a Archive) RecordPath() string {
+	return filepath.Join(a.dir, a.id.AsString()+".json")
+}
+
+// Delete succeeds if the snapshot was already deleted.
+func (a Archive) Delete() error {
+	a.logger.Debug("Archive", "Deleting snapshot '%s'", a.id)
+
+	// IDs end up in file paths on the Docker host
+	if strings.ContainsAny(a.id.AsString(), `/\`) {
+		return ErrUnknownID
+	}
+
+	cmd := host.Cmd{
+		Script: `rm -f "$ARCHIVE" "$ARCHIVE.tmp" "$RECORD_PATH"`,
+		Env:    []string{"ARCHIVE=" + a.ArchivePath(), "RECORD_PATH=" + a.RecordPath()},
+		Binds:  []string{a.dir + ":" + a.dir},
+	}
+
+	_, err := a.runner.Run(cmd)
+	if err != nil {
+		return bosherr.WrapError(err, "Removing snapshot archive")
+	}
+
+	return nil
+}
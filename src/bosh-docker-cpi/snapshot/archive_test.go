@@ -0,0 +1,52 @@
+package snapshot_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/host/hostfakes"
+	. "bosh-docker-cpi/snapshot"
+)
+
+var _ = Describe("Archive", func() {
+	var (
+		runner  *hostfakes.FakeRunner
+		archive Archive
+	)
+
+	BeforeEach(func() {
+		runner = &hostfakes.FakeRunner{}
+		archive = NewArchive(apiv1.NewSnapshotCID("snap-123"), "/var/lib/snapshots", runner, boshlog.NewLogger(boshlog.LevelNone))
+	})
+
+	Describe("Delete", func() {
+		It("force removes archive and record so that deleting twice succeeds", func() {
+			Expect(archive.Delete()).To(Succeed())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Script).To(HavePrefix("rm -f "))
+			Expect(cmd.Env).To(ConsistOf(
+				"ARCHIVE=/var/lib/snapshots/snap-123.tgz",
+				"RECORD_PATH=/var/lib/snapshots/snap-123.json",
+			))
+			Expect(cmd.Binds).To(Equal([]string{"/var/lib/snapshots:/var/lib/snapshots"}))
+		})
+
+		It("returns ErrUnknownID without removing anything for IDs with path separators", func() {
+			archive = NewArchive(apiv1.NewSnapshotCID("snap-../../etc"), "/var/lib/snapshots", runner, boshlog.NewLogger(boshlog.LevelNone))
+
+			Expect(errors.Is(archive.Delete(), ErrUnknownID)).To(BeTrue())
+			Expect(runner.RunCallCount()).To(Equal(0))
+		})
+
+		It("returns error if removing fails", func() {
+			runner.RunReturns(nil, errors.New("fake-err"))
+
+			Expect(archive.Delete()).To(MatchError(ContainSubstring("fake-err")))
+		})
+	})
+})
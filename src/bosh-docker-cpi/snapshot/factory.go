@@ -0,0 +1,111 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+
+	"bosh-docker-cpi/host"
+)
+
+const cidPrefix = "snap-"
+
+// ErrDisabled is returned when no snapshot directory is configured.
+var ErrDisabled = errors.New("Snapshots are disabled since no snapshot directory is configured")
+
+// ErrUnknownID is returned for snapshot IDs the CPI could not have created,
+// e.g. the empty IDs returned before snapshots were implemented.
+var ErrUnknownID = errors.New("Snapshot ID was not created by the CPI")
+
+// Factory stores snapshots as compressed archives of volume contents
+// in a directory on the Docker host. Each archive is accompanied by
+// a JSON record of the disk it was taken from and its metadata.
+type Factory struct {
+	dir     string
+	runner  host.Runner
+	uuidGen boshuuid.Generator
+
+	logTag string
+	logger boshlog.Logger
+}
+
+func NewFactory(dir string, runner host.Runner, uuidGen boshuuid.Generator, logger boshlog.Logger) Factory {
+	return Factory{
+		dir:     dir,
+		runner:  runner,
+		uuidGen: uuidGen,
+
+		logTag: "snapshot.Factory",
+		logger: logger,
+	}
+}
+
+type record struct {
+	DiskCID  string         `json:"disk_cid"`
+	Metadata apiv1.DiskMeta `json:"metadata"`
+}
+
+// Create archives contents of a volume. The volume must exist since Docker
+// would otherwise create an empty one when binding it.
+func (f Factory) Create(diskCID apiv1.DiskCID, meta apiv1.DiskMeta) (Snapshot, error) {
+	if len(f.dir) == 0 {
+		return nil, ErrDisabled
+	}
+
+	f.logger.Debug(f.logTag, "Creating snapshot of disk '%s'", diskCID)
+
+	id, err := f.uuidGen.Generate()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Generating snapshot ID")
+	}
+
+	snap := NewArchive(apiv1.NewSnapshotCID(cidPrefix+id), f.dir, f.runner, f.logger)
+
+	recordBytes, err := json.Marshal(record{DiskCID: diskCID.AsString(), Metadata: meta})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshaling snapshot record")
+	}
+
+	cmd := host.Cmd{
+		Script: `set -e
+mkdir -p "$(dirname "$ARCHIVE")"
+tar -czf "$ARCHIVE.tmp" -C /disk .
+mv "$ARCHIVE.tmp" "$ARCHIVE"
+printf '%s' "$RECORD" > "$RECORD_PATH"`,
+		Env: []string{
+			"ARCHIVE=" + snap.ArchivePath(),
+			"RECORD=" + string(recordBytes),
+			"RECORD_PATH=" + snap.RecordPath(),
+		},
+		Binds: []string{diskCID.AsString() + ":/disk:ro", f.dir + ":" + f.dir},
+	}
+
+	_, err = f.runner.Run(cmd)
+	if err != nil {
+		delErr := snap.Delete()
+		if delErr != nil {
+			f.logger.Error(f.logTag, "Failed cleaning up snapshot '%s': %s", snap.ID(), delErr)
+		}
+
+		return nil, bosherr.WrapErrorf(err, "Archiving disk '%s'", diskCID)
+	}
+
+	return snap, nil
+}
+
+func (f Factory) Find(id apiv1.SnapshotCID) (Snapshot, error) {
+	if !strings.HasPrefix(id.AsString(), cidPrefix) {
+		return nil, ErrUnknownID
+	}
+
+	if len(f.dir) == 0 {
+		return nil, ErrDisabled
+	}
+
+	return NewArchive(id, f.dir, f.runner, f.logger), nil
+}
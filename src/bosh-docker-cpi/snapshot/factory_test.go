@@ -0,0 +1,93 @@
+package snapshot_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/host/hostfakes"
+	. "bosh-docker-cpi/snapshot"
+)
+
+var _ = Describe("Factory", func() {
+	var (
+		runner  *hostfakes.FakeRunner
+		factory Factory
+	)
+
+	BeforeEach(func() {
+		runner = &hostfakes.FakeRunner{}
+		logger := boshlog.NewLogger(boshlog.LevelNone)
+		factory = NewFactory("/var/lib/snapshots", runner, boshuuid.NewGenerator(), logger)
+	})
+
+	Describe("Create", func() {
+		It("archives the volume and records the disk metadata", func() {
+			meta := apiv1.NewDiskMeta(map[string]interface{}{"deployment": "cf"})
+
+			snap, err := factory.Create(apiv1.NewDiskCID("vol-123"), meta)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(snap.ID().AsString()).To(MatchRegexp(`^snap-[0-9a-f-]{36}$`))
+
+			Expect(runner.RunCallCount()).To(Equal(1))
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Script).To(ContainSubstring(`tar -czf "$ARCHIVE.tmp" -C /disk .`))
+			Expect(cmd.Binds).To(Equal([]string{"vol-123:/disk:ro", "/var/lib/snapshots:/var/lib/snapshots"}))
+			Expect(cmd.Env).To(ContainElement("ARCHIVE=/var/lib/snapshots/" + snap.ID().AsString() + ".tgz"))
+			Expect(cmd.Env).To(ContainElement("RECORD_PATH=/var/lib/snapshots/" + snap.ID().AsString() + ".json"))
+
+			var recordBytes []byte
+			for _, env := range cmd.Env {
+				if len(env) > 7 && env[:7] == "RECORD=" {
+					recordBytes = []byte(env[7:])
+				}
+			}
+
+			var record map[string]interface{}
+			Expect(json.Unmarshal(recordBytes, &record)).To(Succeed())
+			Expect(record).To(Equal(map[string]interface{}{
+				"disk_cid": "vol-123",
+				"metadata": map[string]interface{}{"deployment": "cf"},
+			}))
+		})
+
+		It("cleans up and returns error if archiving fails", func() {
+			runner.RunReturnsOnCall(0, nil, errors.New("fake-err"))
+
+			_, err := factory.Create(apiv1.NewDiskCID("vol-123"), apiv1.DiskMeta{})
+			Expect(err).To(MatchError(ContainSubstring("fake-err")))
+
+			Expect(runner.RunCallCount()).To(Equal(2))
+			Expect(runner.RunArgsForCall(1).Script).To(ContainSubstring("rm -f"))
+		})
+
+		It("returns ErrDisabled without a snapshot directory", func() {
+			factory = NewFactory("", runner, boshuuid.NewGenerator(), boshlog.NewLogger(boshlog.LevelNone))
+
+			_, err := factory.Create(apiv1.NewDiskCID("vol-123"), apiv1.DiskMeta{})
+			Expect(errors.Is(err, ErrDisabled)).To(BeTrue())
+			Expect(runner.RunCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("Find", func() {
+		It("returns snapshot archive", func() {
+			snap, err := factory.Find(apiv1.NewSnapshotCID("snap-123"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(snap.ID()).To(Equal(apiv1.NewSnapshotCID("snap-123")))
+		})
+
+		It("returns ErrUnknownID for IDs without the snapshot prefix", func() {
+			_, err := factory.Find(apiv1.NewSnapshotCID("vol-123"))
+			Expect(errors.Is(err, ErrUnknownID)).To(BeTrue())
+
+			_, err = factory.Find(apiv1.SnapshotCID{})
+			Expect(errors.Is(err, ErrUnknownID)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,27 @@
+package snapshot
+
+import (
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+)
+
+//go:generate go tool counterfeiter -generate
+
+//counterfeiter:generate . Creator
+
+type Creator interface {
+	Create(apiv1.DiskCID, apiv1.DiskMeta) (Snapshot, error)
+}
+
+//counterfeiter:generate . Finder
+
+type Finder interface {
+	Find(apiv1.SnapshotCID) (Snapshot, error)
+}
+
+//counterfeiter:generate . Snapshot
+
+type Snapshot interface {
+	ID() apiv1.SnapshotCID
+
+	Delete() error
+}
@@ -66,8 +66,13 @@ func (i FSImporter) ImportFromPath(imagePath string) (Stemcell, error) {
 		return nil, bosherr.WrapErrorf(err, "Reading image archive '%s'", imagePath)
 	}
 
+	// Init system is detected while importing instead of reading the archive twice
+	initDetector, source := NewInitDetector(gzipReader)
+
+	defer initDetector.Result() //nolint:errcheck
+
 	src := dkrimages.ImportSource{
-		Source:     gzipReader,
+		Source:     source,
 		SourceName: "-",
 	}
 
@@ -110,6 +115,17 @@ func (i FSImporter) ImportFromPath(imagePath string) (Stemcell, error) {
 
 	cid := repo + ":" + id
 
+	initSystem, err := initDetector.Result()
+	if err != nil {
+		i.logger.Warn(i.logTag, "Unable to detect init system of stemcell, skipping: %s", err)
+	} else if len(initSystem) > 0 {
+		// Labels can only be given before the archive is read, so the image is labeled afterwards
+		err = LabelInit(i.dkrClient, cid, cid, initSystem)
+		if err != nil {
+			i.logger.Warn(i.logTag, "Unable to record init system of stemcell, skipping: %s", err)
+		}
+	}
+
 	return NewImage(apiv1.NewStemcellCID(cid), i.dkrClient, i.logger), nil
 }
 
@@ -6,6 +6,7 @@ import (
 	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
+	cerrdefs "github.com/containerd/errdefs"
 	dkrimages "github.com/docker/docker/api/types/image"
 	dkrclient "github.com/docker/docker/client"
 )
@@ -27,8 +28,25 @@ func (s Image) ID() apiv1.StemcellCID { return s.id }
 func (s Image) Delete() error {
 	s.logger.Debug("Image", "Deleting stemcell '%s'", s.id)
 
+	img, err := s.dkrClient.ImageInspect(context.TODO(), s.id.AsString())
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil
+		}
+
+		return bosherr.WrapErrorf(err, "Inspecting stemcell image")
+	}
+
+	// Images recording the init system depend on the stemcell image
+	_, err = s.dkrClient.ImageRemove(context.TODO(), InitCacheRef(img.ID), dkrimages.RemoveOptions{})
+	if err != nil && !cerrdefs.IsNotFound(err) {
+		return bosherr.WrapErrorf(err, "Deleting recorded init system of stemcell image")
+	}
+
 	// todo remove forcefully?
-	_, err := s.dkrClient.ImageRemove(context.TODO(), s.id.AsString(), dkrimages.RemoveOptions{Force: true})
+	// Untagged parents are pruned, e.g. the image a stemcell was imported as
+	// before its init system was recorded
+	_, err = s.dkrClient.ImageRemove(context.TODO(), s.id.AsString(), dkrimages.RemoveOptions{Force: true, PruneChildren: true})
 	if err != nil {
 		return bosherr.WrapErrorf(err, "Deleting stemcell image")
 	}
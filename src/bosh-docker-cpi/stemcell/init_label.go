@@ -0,0 +1,91 @@
+package stemcell
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	dkrbuild "github.com/docker/docker/api/types/build"
+	dkrclient "github.com/docker/docker/client"
+)
+
+// initCacheRepo holds images recording the init system of stemcell images
+// that cannot be relabeled in place, e.g. light stemcells pulled by digest.
+const initCacheRepo = "bosh.io/stemcell-inits"
+
+// InitCacheRef is the image that records the init system of an image ID.
+func InitCacheRef(imageID string) string {
+	return initCacheRepo + ":" + strings.TrimPrefix(imageID, "sha256:")
+}
+
+// LabelInit tags an image that only adds InitLabel to the image ref.
+// Unlike committing a container, building keeps the rest of its config.
+func LabelInit(dkrClient *dkrclient.Client, ref, tag, initSystem string) error {
+	buildContext, err := initBuildContext(ref)
+	if err != nil {
+		return err
+	}
+
+	opts := dkrbuild.ImageBuildOptions{
+		Tags:        []string{tag},
+		Labels:      map[string]string{InitLabel: initSystem},
+		Remove:      true,
+		ForceRemove: true,
+		Version:     dkrbuild.BuilderV1, // no BuildKit session needed for a metadata-only image
+	}
+
+	resp, err := dkrClient.ImageBuild(context.TODO(), buildContext, opts)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Labeling image '%s'", ref)
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	dec := json.NewDecoder(resp.Body)
+
+	for {
+		var jm dockerJSONMessage
+
+		err := dec.Decode(&jm)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return bosherr.WrapError(err, "Decoding event from labeling")
+		}
+
+		if jm.Error != nil {
+			return bosherr.WrapErrorf(jm.Error.Error(), "Labeling image '%s'", ref)
+		}
+	}
+}
+
+// initBuildContext is a tar archive holding a Dockerfile based on ref.
+func initBuildContext(ref string) (io.Reader, error) {
+	dockerfile := []byte("FROM " + ref + "\n")
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	err := tw.WriteHeader(&tar.Header{Name: "Dockerfile", Mode: 0644, Size: int64(len(dockerfile))})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Writing build context")
+	}
+
+	_, err = tw.Write(dockerfile)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Writing build context")
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Writing build context")
+	}
+
+	return &buf, nil
+}
@@ -0,0 +1,35 @@
+package stemcell
+
+import (
+	"archive/tar"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("initBuildContext", func() {
+	It("holds a Dockerfile based on the image", func() {
+		buildContext, err := initBuildContext("bosh.io/stemcells:123")
+		Expect(err).NotTo(HaveOccurred())
+
+		tr := tar.NewReader(buildContext)
+
+		hdr, err := tr.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hdr.Name).To(Equal("Dockerfile"))
+
+		contents, err := io.ReadAll(tr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(Equal("FROM bosh.io/stemcells:123\n"))
+
+		_, err = tr.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+})
+
+var _ = Describe("InitCacheRef", func() {
+	It("is keyed by the image ID without its algorithm", func() {
+		Expect(InitCacheRef("sha256:abc")).To(Equal("bosh.io/stemcell-inits:abc"))
+	})
+})
@@ -0,0 +1,98 @@
+package stemcell
+
+import (
+	"archive/tar"
+	"io"
+	"path"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// InitLabel records the init system of a stemcell image. It is added when
+// importing stemcell archives and may be set by light stemcell images.
+const InitLabel = "bosh.init"
+
+const (
+	InitRunit   = "runit"
+	InitSystemd = "systemd"
+)
+
+// InitProbeScript prints the init system of the image it runs in following
+// the same rules as DetectInit, or nothing when unknown.
+const InitProbeScript = `if [ -x /usr/sbin/runsvdir-start ] || [ -x /sbin/runsvdir-start ]; then
+  echo ` + InitRunit + `
+elif readlink -f /sbin/init | grep -q systemd; then
+  echo ` + InitSystemd + `
+fi`
+
+// DetectInit scans a root file system archive for the init system it boots.
+// Images shipping runit's runsvdir-start are started with runit, which works
+// for every cgroup version; otherwise images whose /sbin/init links to systemd
+// are started with systemd. An empty string is returned when neither is found.
+func DetectInit(archive io.Reader) (string, error) {
+	var systemd bool
+
+	tr := tar.NewReader(archive)
+
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", bosherr.WrapError(err, "Reading image archive")
+		}
+
+		switch strings.TrimPrefix(path.Clean("/"+hdr.Name), "/usr") {
+		case "/sbin/runsvdir-start":
+			return InitRunit, nil
+		case "/sbin/init":
+			systemd = strings.Contains(hdr.Linkname, "systemd")
+		}
+	}
+
+	if systemd {
+		return InitSystemd, nil
+	}
+
+	return "", nil
+}
+
+// InitDetector runs DetectInit on an archive while something else reads it,
+// so that large archives only need to be read once.
+type InitDetector struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+
+	initSystem string
+	err        error
+}
+
+// NewInitDetector returns a detector and a reader of archive; everything read
+// through the reader is scanned by the detector.
+func NewInitDetector(archive io.Reader) (*InitDetector, io.Reader) {
+	pr, pw := io.Pipe()
+
+	d := &InitDetector{pw: pw, done: make(chan struct{})}
+
+	go func() {
+		defer close(d.done)
+
+		d.initSystem, d.err = DetectInit(pr)
+
+		// Keep draining so that reads of the archive never block on the scan
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+
+	return d, io.TeeReader(archive, pw)
+}
+
+// Result stops scanning and returns the init system found in what was read so far.
+func (d *InitDetector) Result() (string, error) {
+	_ = d.pw.Close()
+
+	<-d.done
+
+	return d.initSystem, d.err
+}
@@ -0,0 +1,82 @@
+package stemcell_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	bstem "bosh-docker-cpi/stemcell"
+)
+
+func buildArchive(hdrs ...*tar.Header) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range hdrs {
+		Expect(tw.WriteHeader(hdr)).To(Succeed())
+	}
+	Expect(tw.Close()).To(Succeed())
+
+	return &buf
+}
+
+var _ = Describe("DetectInit", func() {
+	It("detects runit stemcells", func() {
+		archive := buildArchive(
+			&tar.Header{Name: "./sbin/init", Typeflag: tar.TypeSymlink, Linkname: "/lib/systemd/systemd"},
+			&tar.Header{Name: "./usr/sbin/runsvdir-start", Typeflag: tar.TypeReg, Mode: 0755},
+		)
+		Expect(bstem.DetectInit(archive)).To(Equal("runit"))
+	})
+
+	It("detects systemd stemcells with a merged /usr", func() {
+		archive := buildArchive(
+			&tar.Header{Name: "sbin", Typeflag: tar.TypeSymlink, Linkname: "usr/sbin"},
+			&tar.Header{Name: "usr/sbin/init", Typeflag: tar.TypeSymlink, Linkname: "../lib/systemd/systemd"},
+		)
+		Expect(bstem.DetectInit(archive)).To(Equal("systemd"))
+	})
+
+	It("returns nothing for other images", func() {
+		archive := buildArchive(&tar.Header{Name: "bin/bash", Typeflag: tar.TypeReg, Mode: 0755})
+		Expect(bstem.DetectInit(archive)).To(BeEmpty())
+	})
+
+	It("returns an error for malformed archives", func() {
+		_, err := bstem.DetectInit(bytes.NewBufferString("not a tar archive, but long enough to hold a block of data..."))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("InitDetector", func() {
+	It("detects the init system of everything read through it", func() {
+		archive := buildArchive(
+			&tar.Header{Name: "./usr/sbin/runsvdir-start", Typeflag: tar.TypeReg, Mode: 0755},
+			&tar.Header{Name: "./var/vcap/bosh/bin/bosh-agent", Typeflag: tar.TypeReg, Mode: 0755},
+		)
+		archiveBytes := archive.Bytes()
+
+		detector, reader := bstem.NewInitDetector(bytes.NewReader(archiveBytes))
+
+		readBytes, err := io.ReadAll(reader)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(readBytes).To(Equal(archiveBytes))
+
+		Expect(detector.Result()).To(Equal("runit"))
+	})
+
+	It("returns an error when the archive was not read to the end", func() {
+		archive := buildArchive(&tar.Header{Name: "bin/bash", Typeflag: tar.TypeReg, Mode: 0755})
+
+		detector, reader := bstem.NewInitDetector(archive)
+
+		_, err := reader.Read(make([]byte, 10))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = detector.Result()
+		Expect(err).To(HaveOccurred())
+	})
+})
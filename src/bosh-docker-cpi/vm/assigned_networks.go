@@ -0,0 +1,99 @@
+package vm
+
+import (
+	"encoding/json"
+	gonet "net"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	dkrnet "github.com/docker/docker/api/types/network"
+)
+
+// assignedNetworks returns copies of networks filled in with the addresses
+// and MACs Docker assigned to the container's endpoints, so that the Director
+// learns IPs of dynamic networks. dkrNames maps BOSH network names to
+// Docker network names (see Networks.Enable).
+func assignedNetworks(
+	networks apiv1.Networks,
+	dkrNames map[string]string,
+	endpoints map[string]*dkrnet.EndpointSettings,
+) (apiv1.Networks, error) {
+	specs := map[string]map[string]interface{}{}
+	macs := map[string]string{}
+
+	for name, net := range networks {
+		spec, err := networkSpec(net)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Marshaling network '%s'", name)
+		}
+
+		specs[name] = spec
+
+		if net.Type() == VIPNetworkType {
+			continue // addresses of the Docker host
+		}
+
+		endpoint := endpoints[dkrNames[name]]
+		if endpoint == nil {
+			return nil, bosherr.Errorf("Expected container to be connected to Docker network '%s' for network '%s'", dkrNames[name], name)
+		}
+
+		ip, prefixLen, gateway, bits := endpoint.IPAddress, endpoint.IPPrefixLen, endpoint.Gateway, 32
+
+		if newIPAddr(net.IP()).IsV6() || len(ip) == 0 {
+			ip, prefixLen, gateway, bits = endpoint.GlobalIPv6Address, endpoint.GlobalIPv6PrefixLen, endpoint.IPv6Gateway, 128
+		}
+
+		if len(ip) == 0 {
+			return nil, bosherr.Errorf("Expected Docker to assign an IP address for network '%s'", name)
+		}
+
+		spec["ip"] = ip
+		spec["netmask"] = gonet.IP(gonet.CIDRMask(prefixLen, bits)).String()
+
+		if len(gateway) > 0 {
+			spec["gateway"] = gateway
+		}
+
+		macs[name] = endpoint.MacAddress
+	}
+
+	specsBytes, err := json.Marshal(specs)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshaling networks")
+	}
+
+	var assigned apiv1.Networks
+
+	err = json.Unmarshal(specsBytes, &assigned)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, net := range assigned {
+		net.SetPreconfigured()
+
+		if len(macs[name]) > 0 {
+			net.SetMAC(macs[name])
+		}
+	}
+
+	return assigned, nil
+}
+
+// networkSpec round trips through JSON since apiv1.NewNetwork drops cloud properties
+func networkSpec(net apiv1.Network) (map[string]interface{}, error) {
+	netBytes, err := json.Marshal(net)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec map[string]interface{}
+
+	err = json.Unmarshal(netBytes, &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
@@ -0,0 +1,90 @@
+package vm
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	dkrnet "github.com/docker/docker/api/types/network"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("assignedNetworks", func() {
+	var (
+		networks apiv1.Networks
+	)
+
+	BeforeEach(func() {
+		err := json.Unmarshal([]byte(`{
+			"dyn": {"type": "dynamic", "default": ["dns", "gateway"], "dns": ["8.8.8.8"], "cloud_properties": {"name": "bosh-dyn"}},
+			"v6": {"type": "manual", "ip": "fd00::10", "netmask": "ffff:ffff:ffff:ffff::", "cloud_properties": {"name": "bosh-v6"}}
+		}`), &networks)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("fills in addresses and MACs assigned by Docker", func() {
+		endpoints := map[string]*dkrnet.EndpointSettings{
+			"bosh-dyn": {
+				IPAddress: "172.18.0.5", IPPrefixLen: 16, Gateway: "172.18.0.1",
+				MacAddress: "02:42:ac:12:00:05",
+			},
+			"bosh-v6": {
+				GlobalIPv6Address: "fd00::10", GlobalIPv6PrefixLen: 64, IPv6Gateway: "fd00::1",
+				IPAddress: "172.19.0.2", IPPrefixLen: 16, Gateway: "172.19.0.1",
+				MacAddress: "02:42:ac:13:00:02",
+			},
+		}
+
+		assigned, err := assignedNetworks(networks, map[string]string{"dyn": "bosh-dyn", "v6": "bosh-v6"}, endpoints)
+		Expect(err).NotTo(HaveOccurred())
+
+		dyn := assigned["dyn"]
+		Expect(dyn.Type()).To(Equal("dynamic"))
+		Expect(dyn.IP()).To(Equal("172.18.0.5"))
+		Expect(dyn.Netmask()).To(Equal("255.255.0.0"))
+		Expect(dyn.Gateway()).To(Equal("172.18.0.1"))
+		Expect(dyn.DNS()).To(Equal([]string{"8.8.8.8"}))
+		Expect(dyn.IsDefaultFor("gateway")).To(BeTrue())
+
+		var netProps NetProps
+		Expect(dyn.CloudProps().As(&netProps)).To(Succeed())
+		Expect(netProps.Name).To(Equal("bosh-dyn"))
+
+		v6 := assigned["v6"]
+		Expect(v6.IP()).To(Equal("fd00::10"))
+		Expect(v6.Netmask()).To(Equal("ffff:ffff:ffff:ffff::"))
+		Expect(v6.Gateway()).To(Equal("fd00::1"))
+
+		agentEnv := apiv1.AgentEnvFactory{}.ForVM(
+			apiv1.NewAgentID("agent"), apiv1.NewVMCID("c-123"), assigned,
+			apiv1.NewVMEnv(nil), apiv1.AgentOptions{})
+
+		agentEnvBytes, err := agentEnv.AsBytes()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(agentEnvBytes)).To(ContainSubstring(`"mac":"02:42:ac:12:00:05"`))
+		Expect(string(agentEnvBytes)).To(ContainSubstring(`"preconfigured":true`))
+	})
+
+	It("keeps vip networks as they are", func() {
+		var vipNetworks apiv1.Networks
+		err := json.Unmarshal([]byte(`{"public": {"type": "vip", "ip": "203.0.113.10", "cloud_properties": {}}}`), &vipNetworks)
+		Expect(err).NotTo(HaveOccurred())
+
+		assigned, err := assignedNetworks(vipNetworks, map[string]string{}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assigned["public"].Type()).To(Equal("vip"))
+		Expect(assigned["public"].IP()).To(Equal("203.0.113.10"))
+	})
+
+	It("returns error when the container is not connected to a network", func() {
+		_, err := assignedNetworks(networks, map[string]string{"dyn": "bosh-dyn", "v6": "bosh-v6"}, nil)
+		Expect(err).To(MatchError(ContainSubstring("Expected container to be connected to Docker network")))
+	})
+
+	It("returns error when Docker did not assign an address", func() {
+		endpoints := map[string]*dkrnet.EndpointSettings{"bosh-dyn": {}, "bosh-v6": {}}
+
+		_, err := assignedNetworks(networks, map[string]string{"dyn": "bosh-dyn", "v6": "bosh-v6"}, endpoints)
+		Expect(err).To(MatchError(ContainSubstring("Expected Docker to assign an IP address")))
+	})
+})
@@ -2,7 +2,11 @@ package vm
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,35 +24,52 @@ import (
 	bdisk "bosh-docker-cpi/disk"
 )
 
-const UpdateSettingsPath = "/var/vcap/bosh/update_settings.json"
-const DnsRecordsPath = "/var/vcap/instance/dns/records.json"
+// PersistentDiskMountDir is where persistent disk volumes are bound inside
+// the container; the agent bind mounts them to /var/vcap/store from there.
+const PersistentDiskMountDir = "/warden-cpi-dev"
+
+// rebootStopTimeout is how many seconds the init system gets to shut down
+// during Reboot before Docker kills the container.
+const rebootStopTimeout = 30
 
 type Container struct {
 	id apiv1.VMCID
 
 	dkrClient       *dkrclient.Client
+	fileService     FileService
 	agentEnvService AgentEnvService
+	hotAttacher     HotAttacher
+	ephemeralDisks  EphemeralDisks
+	metaStore       MetadataStore
 
-	logger boshlog.Logger
-}
+	// preservedPaths are carried over when the container is recreated
+	preservedPaths []string
 
-type EphemeralDiskCID struct {
-	id apiv1.VMCID
+	logger boshlog.Logger
 }
 
-func (c EphemeralDiskCID) AsString() string { return "vol-eph-" + c.id.AsString() }
-
 func NewContainer(
 	id apiv1.VMCID,
 	dkrClient *dkrclient.Client,
+	fileService FileService,
 	agentEnvService AgentEnvService,
+	hotAttacher HotAttacher,
+	ephemeralDisks EphemeralDisks,
+	metaStore MetadataStore,
+	preservedPaths []string,
 	logger boshlog.Logger,
 ) Container {
 	return Container{
 		id: id,
 
 		dkrClient:       dkrClient,
+		fileService:     fileService,
 		agentEnvService: agentEnvService,
+		hotAttacher:     hotAttacher,
+		ephemeralDisks:  ephemeralDisks,
+		metaStore:       metaStore,
+
+		preservedPaths: preservedPaths,
 
 		logger: logger,
 	}
@@ -57,35 +78,53 @@ func NewContainer(
 func (c Container) ID() apiv1.VMCID { return c.id }
 
 func (c Container) Delete() error {
-	exists, err := c.Exists()
+	err := c.removeContainer()
 	if err != nil {
 		return err
 	}
 
-	if exists {
-		err := c.tryKilling() // todo just make this idempotent
-		if err != nil {
-			return err
-		}
+	err = c.ephemeralDisks.Delete(c.id)
+	if err != nil {
+		return err
+	}
 
-		rmOpts := container.RemoveOptions{Force: true}
+	err = c.metaStore.Delete(c.id)
+	if err != nil {
+		return err
+	}
 
-		// todo handle 'device or resource busy' error?
-		err = c.dkrClient.ContainerRemove(context.TODO(), c.id.AsString(), rmOpts)
-		if err != nil {
-			// todo how to best handle rootfs removal e.g.:
-			// ... remove root filesystem xxx-removing: device or resource busy
-			// ... remove root filesystem xxx: layer not retained
-			if !strings.Contains(err.Error(), "Driver aufs failed to remove root filesystem") {
-				return err
-			}
-		}
+	if c.hotAttacher.Enabled() {
+		return c.hotAttacher.Cleanup(c.id)
 	}
 
-	err = c.dkrClient.VolumeRemove(context.TODO(), EphemeralDiskCID{c.id}.AsString(), true)
+	return nil
+}
+
+func (c Container) removeContainer() error {
+	exists, err := c.Exists()
 	if err != nil {
-		if !cerrdefs.IsNotFound(err) {
-			return bosherr.WrapErrorf(err, "Deleting ephemeral volume")
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	err = c.tryKilling() // todo just make this idempotent
+	if err != nil {
+		return err
+	}
+
+	rmOpts := container.RemoveOptions{Force: true}
+
+	// todo handle 'device or resource busy' error?
+	err = c.dkrClient.ContainerRemove(context.TODO(), c.id.AsString(), rmOpts)
+	if err != nil {
+		// todo how to best handle rootfs removal e.g.:
+		// ... remove root filesystem xxx-removing: device or resource busy
+		// ... remove root filesystem xxx: layer not retained
+		if !strings.Contains(err.Error(), "Driver aufs failed to remove root filesystem") {
+			return err
 		}
 	}
 
@@ -105,6 +144,55 @@ func (c Container) Exists() (bool, error) {
 	return true, nil
 }
 
+// Reboot stops the container gracefully and starts it again. The container
+// itself is kept, so its binds, networks and writable layer (which holds
+// warden-cpi-agent-env.json, update_settings.json and the DNS records) survive.
+// On start Docker re-runs the container command, repeating the pre-start steps
+// built by Factory.Create before exec'ing the init system.
+func (c Container) Reboot() error {
+	conf, err := c.dkrClient.ContainerInspect(context.TODO(), c.id.AsString())
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return bosherr.Error("VM does not exist")
+		}
+
+		return bosherr.WrapError(err, "Inspecting container")
+	}
+
+	timeout := rebootStopTimeout
+
+	// Docker sends the container's stop signal (see Factory.Create),
+	// and SIGKILL once the timeout expires
+	stopOpts := container.StopOptions{Timeout: &timeout, Signal: legacyStopSignal(conf.Config)}
+
+	err = c.dkrClient.ContainerStop(context.TODO(), c.id.AsString(), stopOpts)
+	if err != nil {
+		return bosherr.WrapError(err, "Stopping container")
+	}
+
+	err = c.dkrClient.ContainerStart(context.TODO(), c.id.AsString(), container.StartOptions{})
+	if err != nil {
+		return bosherr.WrapError(err, "Starting container")
+	}
+
+	return nil
+}
+
+// legacyStopSignal returns the signal systemd shuts down on for systemd
+// containers created without a stop signal, which would otherwise ignore
+// SIGTERM until they are killed.
+func legacyStopSignal(conf *container.Config) string {
+	if conf == nil || len(conf.StopSignal) > 0 || len(conf.Cmd) == 0 {
+		return ""
+	}
+
+	if strings.HasSuffix(conf.Cmd[len(conf.Cmd)-1], "exec /sbin/init") {
+		return systemdStopSignal
+	}
+
+	return ""
+}
+
 func (c Container) tryKilling() error {
 	var lastErr error
 
@@ -125,144 +213,373 @@ func (c Container) tryKilling() error {
 	return bosherr.WrapError(lastErr, "Killing container")
 }
 
-func (c Container) AttachDisk(disk bdisk.Disk) (apiv1.DiskHint, error) {
-	exists, err := c.Exists()
+// DiskIDs returns the persistent disks bound into the container, leaving out
+// the ephemeral disk, along with hot attached disks known to the agent env.
+func (c Container) DiskIDs() ([]apiv1.DiskCID, error) {
+	conf, err := c.dkrClient.ContainerInspect(context.TODO(), c.id.AsString())
 	if err != nil {
-		return apiv1.DiskHint{}, err
+		return nil, bosherr.WrapError(err, "Inspecting container")
 	}
 
-	if !exists {
-		return apiv1.DiskHint{}, bosherr.Error("VM does not exist")
+	diskIDs := persistentDiskIDs(conf.HostConfig.Binds)
+
+	if !hasHotAttachBind(conf.HostConfig.Binds) {
+		return diskIDs, nil
 	}
 
 	agentEnv, err := c.agentEnvService.Fetch()
 	if err != nil {
-		return apiv1.DiskHint{}, bosherr.WrapError(err, "Fetching agent env")
+		return nil, bosherr.WrapError(err, "Fetching agent env")
 	}
 
-	fileService := NewFileService(c.dkrClient, c.id, c.logger)
-
-	updateSettings, err := fileService.Download(UpdateSettingsPath)
+	agentEnvDiskIDs, err := agentEnvPersistentDiskIDs(agentEnv)
 	if err != nil {
-		c.logger.Warn("attach-disk", "Unable to find update_settings.json skipping: %s", err)
+		return nil, err
 	}
-	dnsRecords, err := fileService.Download(DnsRecordsPath)
-	if err != nil {
-		c.logger.Warn("attach-disk", "Unable to find records.json skipping: %s", err)
+
+	for _, diskID := range agentEnvDiskIDs {
+		if !containsDiskID(diskIDs, diskID) {
+			diskIDs = append(diskIDs, diskID)
+		}
 	}
 
-	path := filepath.Join("/warden-cpi-dev", disk.ID().AsString())
-	diskHint := apiv1.NewDiskHintFromString(path)
-	agentEnv.AttachPersistentDisk(disk.ID(), diskHint)
+	return diskIDs, nil
+}
 
-	err = c.restartByRecreating(disk.ID(), path)
+func agentEnvPersistentDiskIDs(agentEnv apiv1.AgentEnv) ([]apiv1.DiskCID, error) {
+	bytes, err := agentEnv.AsBytes()
 	if err != nil {
-		return apiv1.DiskHint{}, bosherr.WrapError(err, "Restarting by recreating")
+		return nil, bosherr.WrapError(err, "Marshaling agent env")
 	}
 
-	err = c.agentEnvService.Update(agentEnv)
+	var spec struct {
+		Disks struct {
+			Persistent map[string]json.RawMessage `json:"persistent"`
+		} `json:"disks"`
+	}
+
+	err = json.Unmarshal(bytes, &spec)
 	if err != nil {
-		return apiv1.DiskHint{}, bosherr.WrapError(err, "Updating agent env")
+		return nil, bosherr.WrapError(err, "Unmarshaling agent env")
 	}
 
-	if len(updateSettings) > 0 {
-		err = fileService.Upload(UpdateSettingsPath, updateSettings)
-		if err != nil {
-			return apiv1.DiskHint{}, bosherr.WrapError(err, "Restoring update_settings.json")
+	var diskIDs []apiv1.DiskCID
+
+	for id := range spec.Disks.Persistent {
+		diskIDs = append(diskIDs, apiv1.NewDiskCID(id))
+	}
+
+	sort.Slice(diskIDs, func(i, j int) bool { return diskIDs[i].AsString() < diskIDs[j].AsString() })
+
+	return diskIDs, nil
+}
+
+func containsDiskID(diskIDs []apiv1.DiskCID, diskID apiv1.DiskCID) bool {
+	for _, id := range diskIDs {
+		if id == diskID {
+			return true
 		}
 	}
-	if len(dnsRecords) > 0 {
-		err = fileService.Upload(DnsRecordsPath, dnsRecords)
+
+	return false
+}
+
+func persistentDiskIDs(binds []string) []apiv1.DiskCID {
+	diskIDs := []apiv1.DiskCID{} // reported as [] rather than null
+
+	for _, bind := range binds {
+		// e.g. vol-123:/warden-cpi-dev/vol-123
+		pieces := strings.Split(bind, ":")
+		if len(pieces) < 2 {
+			continue
+		}
+
+		src, dst := pieces[0], pieces[1]
+
+		if !strings.HasPrefix(src, "vol-") || strings.HasPrefix(src, "vol-eph-") {
+			continue
+		}
+
+		if path.Dir(path.Clean(dst)) != PersistentDiskMountDir {
+			continue
+		}
+
+		diskIDs = append(diskIDs, apiv1.NewDiskCID(src))
+	}
+
+	return diskIDs
+}
+
+func (c Container) AttachDisk(disk bdisk.Disk) (apiv1.DiskHint, error) {
+	diskPath := filepath.Join(PersistentDiskMountDir, disk.ID().AsString())
+	diskHint := apiv1.NewDiskHintFromString(diskPath)
+
+	if c.hotAttacher.Enabled() {
+		attached, err := c.tryHotAttaching(disk.ID(), diskHint)
 		if err != nil {
-			return apiv1.DiskHint{}, bosherr.WrapError(err, "Restoring records.json")
+			return apiv1.DiskHint{}, err
+		}
+
+		if attached {
+			return diskHint, nil
 		}
-		err = c.runInContainer("chgrp vcap " + DnsRecordsPath)
+	}
+
+	updateAgentEnv := func(agentEnv apiv1.AgentEnv) {
+		agentEnv.AttachPersistentDisk(disk.ID(), diskHint)
+	}
+
+	updateConf := func(conf *dkrtypes.ContainerJSON) error { //nolint:staticcheck
+		err := c.scheduleWithDisk(conf, disk.ID())
 		if err != nil {
-			return apiv1.DiskHint{}, bosherr.WrapError(err, "chgrp records.json")
+			return err
 		}
+
+		conf.HostConfig.Binds = c.updateBinds(conf.HostConfig.Binds, disk.ID(), diskPath)
+
+		return nil
+	}
+
+	err := c.recreatePreservingAgentState("attach-disk", updateAgentEnv, updateConf)
+	if err != nil {
+		return apiv1.DiskHint{}, err
 	}
 
 	return diskHint, nil
 }
 
 func (c Container) DetachDisk(disk bdisk.Disk) error {
-	exists, err := c.Exists()
-	if err != nil {
-		return err
+	if c.hotAttacher.Enabled() {
+		detached, err := c.tryHotDetaching(disk.ID())
+		if err != nil {
+			return err
+		}
+
+		if detached {
+			return nil
+		}
 	}
 
-	if !exists {
-		return bosherr.Error("VM does not exist")
+	updateAgentEnv := func(agentEnv apiv1.AgentEnv) {
+		agentEnv.DetachPersistentDisk(disk.ID())
+	}
+
+	updateConf := func(conf *dkrtypes.ContainerJSON) error { //nolint:staticcheck
+		err := c.scheduleWithDisk(conf, disk.ID())
+		if err != nil {
+			return err
+		}
+
+		conf.HostConfig.Binds = c.updateBinds(conf.HostConfig.Binds, disk.ID(), "")
+
+		return nil
+	}
+
+	return c.recreatePreservingAgentState("detach-disk", updateAgentEnv, updateConf)
+}
+
+// tryHotAttaching mounts the disk into the running container and updates the
+// agent env in place. It reports false when the container or volume does not
+// support it, in which case the container has to be recreated.
+func (c Container) tryHotAttaching(diskID apiv1.DiskCID, diskHint apiv1.DiskHint) (bool, error) {
+	hotAttachable, err := c.hotAttachable()
+	if err != nil || !hotAttachable {
+		return false, err
 	}
 
 	agentEnv, err := c.agentEnvService.Fetch()
 	if err != nil {
-		return bosherr.WrapError(err, "Fetching agent env")
+		return false, bosherr.WrapError(err, "Fetching agent env")
 	}
 
-	fileService := NewFileService(c.dkrClient, c.id, c.logger)
+	err = c.hotAttacher.Mount(c.id, diskID)
+	if err != nil {
+		if errors.Is(err, errNotHotAttachable) {
+			c.logger.Debug("attach-disk", "Falling back to recreating container for disk '%s'", diskID)
+			return false, nil
+		}
+
+		return false, err
+	}
 
-	updateSettings, err := fileService.Download(UpdateSettingsPath)
+	agentEnv.AttachPersistentDisk(diskID, diskHint)
+
+	err = c.agentEnvService.Update(agentEnv)
 	if err != nil {
-		c.logger.Warn("detach-disk", "Unable to find update_settings.json skipping: %s", err)
+		return false, bosherr.WrapError(err, "Updating agent env")
 	}
-	dnsRecords, err := fileService.Download(DnsRecordsPath)
+
+	return true, nil
+}
+
+// tryHotDetaching reports false for disks that were bound into the container
+// when it was (re)created since those can only be removed by recreating it.
+func (c Container) tryHotDetaching(diskID apiv1.DiskCID) (bool, error) {
+	conf, err := c.dkrClient.ContainerInspect(context.TODO(), c.id.AsString())
 	if err != nil {
-		c.logger.Warn("detach-disk", "Unable to find records.json skipping: %s", err)
+		if cerrdefs.IsNotFound(err) {
+			return false, bosherr.Error("VM does not exist")
+		}
+
+		return false, bosherr.WrapError(err, "Inspecting container")
 	}
 
-	agentEnv.DetachPersistentDisk(disk.ID())
+	binds := conf.HostConfig.Binds
 
-	err = c.restartByRecreating(disk.ID(), "")
+	if !hasHotAttachBind(binds) || containsDiskID(persistentDiskIDs(binds), diskID) {
+		return false, nil
+	}
+
+	agentEnv, err := c.agentEnvService.Fetch()
 	if err != nil {
-		return bosherr.WrapError(err, "Restarting by recreating")
+		return false, bosherr.WrapError(err, "Fetching agent env")
 	}
 
-	// todo update before starting?
+	err = c.hotAttacher.Unmount(c.id, diskID)
+	if err != nil {
+		return false, err
+	}
+
+	agentEnv.DetachPersistentDisk(diskID)
+
 	err = c.agentEnvService.Update(agentEnv)
 	if err != nil {
-		return bosherr.WrapError(err, "Updating agent env")
+		return false, bosherr.WrapError(err, "Updating agent env")
+	}
+
+	return true, nil
+}
+
+func (c Container) hotAttachable() (bool, error) {
+	conf, err := c.dkrClient.ContainerInspect(context.TODO(), c.id.AsString())
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return false, bosherr.Error("VM does not exist")
+		}
+
+		return false, bosherr.WrapError(err, "Inspecting container")
+	}
+
+	// Containers created before hot attach was enabled lack the bind
+	return hasHotAttachBind(conf.HostConfig.Binds), nil
+}
+
+// SetMetadata records VM metadata next to the container instead of labeling
+// it, since Docker cannot relabel a container without recreating it. Labels
+// catch up whenever the container is recreated for other reasons.
+func (c Container) SetMetadata(meta apiv1.VMMeta) error {
+	if !c.metaStore.Enabled() {
+		return nil
+	}
+
+	exists, err := c.Exists()
+	if err != nil {
+		return err
 	}
 
-	if len(updateSettings) > 0 {
-		err = fileService.Upload(UpdateSettingsPath, updateSettings)
+	if !exists {
+		return bosherr.Error("VM does not exist")
+	}
+
+	values, err := MetadataValues(meta)
+	if err != nil {
+		return err
+	}
+
+	return c.metaStore.Save(c.id, values)
+}
+
+// recreatePreservingAgentState recreates the container with updateConf applied
+// while carrying the agent env (adjusted by updateAgentEnv) and the files the
+// agent wrote at runtime over to the new container.
+func (c Container) recreatePreservingAgentState(
+	logTag string,
+	updateAgentEnv func(apiv1.AgentEnv),
+	updateConf func(*dkrtypes.ContainerJSON) error, //nolint:staticcheck
+) error {
+	exists, err := c.Exists()
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return bosherr.Error("VM does not exist")
+	}
+
+	agentEnv, err := c.agentEnvService.Fetch()
+	if err != nil {
+		return bosherr.WrapError(err, "Fetching agent env")
+	}
+
+	var agentState []byte
+
+	if len(c.preservedPaths) > 0 {
+		agentState, err = c.fileService.DownloadArchive(c.preservedPaths)
 		if err != nil {
-			return bosherr.WrapError(err, "Restoring update_settings.json")
+			return bosherr.WrapError(err, "Saving agent state")
 		}
 	}
-	if len(dnsRecords) > 0 {
-		err = fileService.Upload(DnsRecordsPath, dnsRecords)
+
+	updateAgentEnv(agentEnv)
+
+	err = c.restartByRecreating(updateConf)
+	if err != nil {
+		return bosherr.WrapError(err, "Restarting by recreating")
+	}
+
+	// Restored before the agent env so that a preserved copy cannot overwrite it
+	if len(agentState) > 0 {
+		err = c.fileService.UploadArchive(agentState)
 		if err != nil {
-			return bosherr.WrapError(err, "Restoring records.json")
+			return bosherr.WrapError(err, "Restoring agent state")
 		}
 	}
 
+	// todo update before starting?
+	err = c.agentEnvService.Update(agentEnv)
+	if err != nil {
+		return bosherr.WrapError(err, "Updating agent env")
+	}
+
 	return nil
 }
 
-func (c Container) restartByRecreating(diskID apiv1.DiskCID, diskPath string) error {
+func (c Container) restartByRecreating(updateConf func(*dkrtypes.ContainerJSON) error) error { //nolint:staticcheck
 	conf, err := c.dkrClient.ContainerInspect(context.TODO(), c.id.AsString())
 	if err != nil {
 		return bosherr.WrapError(err, "Inspecting container")
 	}
 
-	err = c.Delete()
+	err = updateConf(&conf)
 	if err != nil {
-		return bosherr.WrapError(err, "Disposing of container before disk attachment")
+		return err
 	}
 
-	node, err := c.findNodeWithDisk(diskID)
+	meta, err := c.metaStore.Get(c.id)
 	if err != nil {
-		return bosherr.WrapError(err, "Finding node for disk")
+		return err
 	}
 
-	if len(node) > 0 {
-		// Hard schedule on the same node that has the disk
-		// todo hopefully swarm handles this functionality in future?
-		conf.Config.Env = []string{"constraint:node==" + node}
+	if len(meta) > 0 {
+		if conf.Config.Labels == nil {
+			conf.Config.Labels = map[string]string{}
+		}
+
+		for key, val := range metadataLabels(meta) {
+			conf.Config.Labels[key] = val
+		}
+	}
+
+	// Recreated systemd containers keep shutting down cleanly on reboot
+	if signal := legacyStopSignal(conf.Config); len(signal) > 0 {
+		conf.Config.StopSignal = signal
 	}
 
-	conf.HostConfig.Binds = c.updateBinds(conf.HostConfig.Binds, diskID, diskPath)
+	// Ephemeral volume is kept so that it is picked up by the new container
+	err = c.removeContainer()
+	if err != nil {
+		return bosherr.WrapError(err, "Disposing of container before recreating")
+	}
 
 	netConfig := c.copyNetworks(conf)
 	netConfig, additionalEndPtConfigs := splitNetworkSettings(netConfig)
@@ -292,13 +609,19 @@ func (c Container) restartByRecreating(diskID apiv1.DiskCID, diskPath string) er
 	return nil
 }
 
-func (c Container) runInContainer(cmd string) error {
-	execProcess, err := c.dkrClient.ContainerExecCreate(context.TODO(), c.id.AsString(), container.ExecOptions{Cmd: []string{"bash", "-c", cmd}})
+// scheduleWithDisk hard schedules the container on the node that has the disk.
+func (c Container) scheduleWithDisk(conf *dkrtypes.ContainerJSON, diskID apiv1.DiskCID) error { //nolint:staticcheck
+	node, err := c.findNodeWithDisk(diskID)
 	if err != nil {
-		return err
+		return bosherr.WrapError(err, "Finding node for disk")
 	}
 
-	return c.dkrClient.ContainerExecStart(context.TODO(), execProcess.ID, container.ExecStartOptions{})
+	if len(node) > 0 {
+		// todo hopefully swarm handles this functionality in future?
+		conf.Config.Env = []string{"constraint:node==" + node}
+	}
+
+	return nil
 }
 
 func (Container) updateBinds(binds []string, diskID apiv1.DiskCID, diskPath string) []string {
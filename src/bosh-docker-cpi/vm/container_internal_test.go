@@ -0,0 +1,103 @@
+package vm
+
+import (
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Container", func() {
+	Describe("legacyStopSignal", func() {
+		It("returns the systemd stop signal for systemd containers created without one", func() {
+			conf := &container.Config{Cmd: []string{"bash", "-c", "umount /etc/hosts && exec /sbin/init"}}
+			Expect(legacyStopSignal(conf)).To(Equal("SIGRTMIN+3"))
+		})
+
+		It("leaves the signal to Docker for containers with a stop signal or runit", func() {
+			Expect(legacyStopSignal(&container.Config{
+				Cmd: []string{"bash", "-c", "exec /sbin/init"}, StopSignal: "SIGRTMIN+3"})).To(BeEmpty())
+			Expect(legacyStopSignal(&container.Config{
+				Cmd: []string{"bash", "-c", "exec env -i /usr/sbin/runsvdir-start"}})).To(BeEmpty())
+			Expect(legacyStopSignal(nil)).To(BeEmpty())
+		})
+	})
+
+	Describe("persistentDiskIDs", func() {
+		It("returns persistent disks bound under /warden-cpi-dev", func() {
+			binds := []string{
+				"vol-eph-c-123:/var/vcap/data/",
+				"/lib/modules:/usr/lib/modules",
+				"vol-abc:/warden-cpi-dev/vol-abc",
+				"vol-def:/warden-cpi-dev/vol-def:rw",
+			}
+
+			Expect(persistentDiskIDs(binds)).To(Equal([]apiv1.DiskCID{
+				apiv1.NewDiskCID("vol-abc"),
+				apiv1.NewDiskCID("vol-def"),
+			}))
+		})
+
+		It("ignores volumes bound elsewhere", func() {
+			Expect(persistentDiskIDs([]string{"vol-abc:/var/vcap/store"})).To(BeEmpty())
+		})
+
+		It("returns an empty rather than nil slice without disks", func() {
+			Expect(persistentDiskIDs(nil)).To(Equal([]apiv1.DiskCID{}))
+		})
+	})
+
+	Describe("hasHotAttachBind", func() {
+		It("returns true when the container directory is bound with rslave propagation", func() {
+			binds := []string{
+				"vol-eph-c-123:/var/vcap/data/",
+				"/var/lib/hot-attach/c-123:/warden-cpi-dev:rslave",
+			}
+			Expect(hasHotAttachBind(binds)).To(BeTrue())
+		})
+
+		It("returns false for containers created without hot attach", func() {
+			Expect(hasHotAttachBind([]string{"vol-abc:/warden-cpi-dev/vol-abc"})).To(BeFalse())
+			Expect(hasHotAttachBind([]string{"/var/lib/hot-attach/c-123:/warden-cpi-dev"})).To(BeFalse())
+		})
+	})
+
+	Describe("hotAttachSource", func() {
+		It("returns the mount point of plain local volumes", func() {
+			source, err := hotAttachSource(volume.Volume{Driver: "local", Mountpoint: "/var/lib/docker/volumes/vol-abc/_data"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(source).To(Equal("/var/lib/docker/volumes/vol-abc/_data"))
+		})
+
+		It("refuses volumes Docker mounts itself or does not know the host path of", func() {
+			loop := volume.Volume{
+				Driver:     "local",
+				Mountpoint: "/var/lib/docker/volumes/vol-abc/_data",
+				Options:    map[string]string{"type": "ext4", "o": "loop", "device": "/var/lib/disks/vol-abc.img"},
+			}
+
+			for _, vol := range []volume.Volume{loop, {Driver: "nfs", Mountpoint: "/mnt"}, {Driver: "local"}} {
+				_, err := hotAttachSource(vol)
+				Expect(err).To(Equal(errNotHotAttachable))
+			}
+		})
+	})
+
+	Describe("agentEnvPersistentDiskIDs", func() {
+		It("returns persistent disks known to the agent env", func() {
+			agentEnv := apiv1.AgentEnvFactory{}.ForVM(
+				apiv1.NewAgentID("agent"), apiv1.NewVMCID("c-123"), apiv1.Networks{},
+				apiv1.NewVMEnv(nil), apiv1.AgentOptions{})
+			agentEnv.AttachPersistentDisk(apiv1.NewDiskCID("vol-def"), apiv1.NewDiskHintFromString("/warden-cpi-dev/vol-def"))
+			agentEnv.AttachPersistentDisk(apiv1.NewDiskCID("vol-abc"), apiv1.NewDiskHintFromString("/warden-cpi-dev/vol-abc"))
+
+			diskIDs, err := agentEnvPersistentDiskIDs(agentEnv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diskIDs).To(Equal([]apiv1.DiskCID{
+				apiv1.NewDiskCID("vol-abc"),
+				apiv1.NewDiskCID("vol-def"),
+			}))
+		})
+	})
+})
@@ -12,8 +12,15 @@ var _ = Describe("Container", func() {
 	Describe("ID", func() {
 		It("returns the VM CID it was created with", func() {
 			vmCID := apiv1.NewVMCID("c-test-vm")
-			container := NewContainer(vmCID, nil, nil, nil)
+			container := NewContainer(vmCID, nil, nil, nil, HotAttacher{}, EphemeralDisks{}, MetadataStore{}, nil, nil)
 			Expect(container.ID()).To(Equal(vmCID))
 		})
 	})
+
+	Describe("SetMetadata", func() {
+		It("discards metadata without touching the container when no metadata dir is configured", func() {
+			container := NewContainer(apiv1.NewVMCID("c-test-vm"), nil, nil, nil, HotAttacher{}, EphemeralDisks{}, MetadataStore{}, nil, nil)
+			Expect(container.SetMetadata(apiv1.NewVMMeta(map[string]interface{}{"deployment": "cf"}))).To(Succeed())
+		})
+	})
 })
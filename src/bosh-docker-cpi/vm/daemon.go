@@ -0,0 +1,48 @@
+package vm
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types/system"
+)
+
+// Daemon describes limits of the Docker daemon that containers run on.
+type Daemon struct {
+	// Rootless is set for rootless and user namespace remapped daemons.
+	// Their containers cannot use host paths such as /sys/fs/cgroup and
+	// cannot unmount the files Docker bind mounts into them.
+	Rootless bool
+
+	// UsernsRemap is set for daemons started with --userns-remap,
+	// which refuse privileged containers
+	UsernsRemap bool
+
+	// CgroupVersion is "1" or "2", or empty when not reported
+	CgroupVersion string
+}
+
+// NewDaemon detects rootless and user namespace remapped daemons from the
+// security options reported by `docker info` (e.g. "name=rootless").
+func NewDaemon(info system.Info) Daemon {
+	daemon := Daemon{CgroupVersion: info.CgroupVersion}
+
+	for _, opt := range info.SecurityOptions {
+		for _, field := range strings.Split(opt, ",") {
+			switch field {
+			case "name=rootless":
+				daemon.Rootless = true
+			case "name=userns":
+				daemon.Rootless = true
+				daemon.UsernsRemap = true
+			}
+		}
+	}
+
+	return daemon
+}
+
+// MountsCgroupfs reports whether the host /sys/fs/cgroup should be bound into
+// systemd containers by default; see cgroupBind for why not on cgroup v1.
+func (d Daemon) MountsCgroupfs() bool {
+	return d.CgroupVersion != "1"
+}
@@ -0,0 +1,40 @@
+package vm_test
+
+import (
+	"github.com/docker/docker/api/types/system"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "bosh-docker-cpi/vm"
+)
+
+var _ = Describe("Daemon", func() {
+	Describe("NewDaemon", func() {
+		It("detects rootless daemons", func() {
+			info := system.Info{SecurityOptions: []string{"name=seccomp,profile=builtin", "name=rootless", "name=cgroupns"}}
+			Expect(NewDaemon(info)).To(Equal(Daemon{Rootless: true}))
+		})
+
+		It("detects user namespace remapped daemons", func() {
+			info := system.Info{SecurityOptions: []string{"name=apparmor", "name=userns"}}
+			Expect(NewDaemon(info)).To(Equal(Daemon{Rootless: true, UsernsRemap: true}))
+		})
+
+		It("treats other daemons as rootful", func() {
+			info := system.Info{SecurityOptions: []string{"name=apparmor", "name=seccomp,profile=builtin"}}
+			Expect(NewDaemon(info)).To(Equal(Daemon{}))
+		})
+
+		It("records the cgroup version", func() {
+			Expect(NewDaemon(system.Info{CgroupVersion: "2"}).CgroupVersion).To(Equal("2"))
+		})
+	})
+
+	Describe("MountsCgroupfs", func() {
+		It("mounts cgroupfs unless the host uses cgroup v1", func() {
+			Expect(Daemon{CgroupVersion: "2"}.MountsCgroupfs()).To(BeTrue())
+			Expect(Daemon{}.MountsCgroupfs()).To(BeTrue())
+			Expect(Daemon{CgroupVersion: "1"}.MountsCgroupfs()).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,107 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/volume"
+	dkrclient "github.com/docker/docker/client"
+
+	bdisk "bosh-docker-cpi/disk"
+)
+
+const (
+	EphemeralDiskTypeLoop  = "loop"
+	EphemeralDiskTypeTmpfs = "tmpfs"
+)
+
+type EphemeralDiskCID struct {
+	id apiv1.VMCID
+}
+
+func (c EphemeralDiskCID) AsString() string { return "vol-eph-" + c.id.AsString() }
+
+// EphemeralDisks manage the vol-eph-<cid> volumes mounted at /var/vcap/data.
+// Without a size Docker creates them on first use and they can grow
+// until the Docker host runs out of space.
+type EphemeralDisks struct {
+	dkrClient *dkrclient.Client
+	loopFiles bdisk.LoopFiles
+}
+
+func NewEphemeralDisks(dkrClient *dkrclient.Client, loopFiles bdisk.LoopFiles) EphemeralDisks {
+	return EphemeralDisks{dkrClient: dkrClient, loopFiles: loopFiles}
+}
+
+// Create creates the ephemeral volume with a fixed size, backed by
+// a loop file or tmpfs. Nothing is created when props have no size.
+func (d EphemeralDisks) Create(id apiv1.VMCID, props EphemeralDiskProps) error {
+	if props.Size == 0 {
+		return nil
+	}
+
+	diskCID := d.diskCID(id)
+	opts := volume.CreateOptions{Name: diskCID.AsString()}
+
+	switch props.TypeOrDefault() {
+	case EphemeralDiskTypeTmpfs:
+		opts.DriverOpts = map[string]string{
+			"type":   "tmpfs",
+			"device": "tmpfs",
+			"o":      fmt.Sprintf("size=%dm", props.Size),
+		}
+
+	case EphemeralDiskTypeLoop:
+		var err error
+
+		opts.DriverOpts, err = d.loopFiles.Create(diskCID, props.Size)
+		if err != nil {
+			return bosherr.WrapError(err, "Creating ephemeral disk")
+		}
+	}
+
+	_, err := d.dkrClient.VolumeCreate(context.TODO(), opts)
+	if err != nil {
+		if props.TypeOrDefault() == EphemeralDiskTypeLoop {
+			_ = d.loopFiles.Delete(d.loopFiles.Path(diskCID))
+		}
+
+		return bosherr.WrapError(err, "Creating ephemeral volume")
+	}
+
+	return nil
+}
+
+// Delete removes the ephemeral volume and its loop file, if any.
+func (d EphemeralDisks) Delete(id apiv1.VMCID) error {
+	diskCID := d.diskCID(id)
+
+	vol, err := d.dkrClient.VolumeInspect(context.TODO(), diskCID.AsString())
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil
+		}
+
+		return bosherr.WrapError(err, "Finding ephemeral volume")
+	}
+
+	err = d.dkrClient.VolumeRemove(context.TODO(), diskCID.AsString(), true)
+	if err != nil {
+		if !cerrdefs.IsNotFound(err) {
+			return bosherr.WrapErrorf(err, "Deleting ephemeral volume")
+		}
+	}
+
+	if loopFile, found := d.loopFiles.BackingFile(diskCID, vol.Options); found {
+		return d.loopFiles.Delete(loopFile)
+	}
+
+	return nil
+}
+
+func (EphemeralDisks) diskCID(id apiv1.VMCID) apiv1.DiskCID {
+	return apiv1.NewDiskCID(EphemeralDiskCID{id}.AsString())
+}
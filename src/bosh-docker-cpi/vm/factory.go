@@ -3,25 +3,39 @@ package vm
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"bosh-docker-cpi/config"
+	bdisk "bosh-docker-cpi/disk"
+	"bosh-docker-cpi/host"
 	bstem "bosh-docker-cpi/stemcell"
 
 	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshlog "github.com/cloudfoundry/bosh-utils/logger"
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
+	cerrdefs "github.com/containerd/errdefs"
 	dkrcont "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	dkrnet "github.com/docker/docker/api/types/network"
 	dkrstrslice "github.com/docker/docker/api/types/strslice"
 	"github.com/docker/docker/api/types/volume"
 	dkrclient "github.com/docker/docker/client"
 	dkrnat "github.com/docker/go-connections/nat"
 )
 
+const systemdStopSignal = "SIGRTMIN+3"
+
 type Factory struct {
-	dkrClient *dkrclient.Client
-	uuidGen   boshuuid.Generator
+	dkrClient      *dkrclient.Client
+	uuidGen        boshuuid.Generator
+	hotAttacher    HotAttacher
+	ephemeralDisks EphemeralDisks
+	lxcfs          LXCFS
+	daemonInfo     *daemonInfo
+	metaStore      MetadataStore
 
 	agentOptions apiv1.AgentOptions
 
@@ -32,14 +46,26 @@ type Factory struct {
 
 func NewFactory(
 	dkrClient *dkrclient.Client,
+	runner host.Runner,
 	uuidGen boshuuid.Generator,
 	agentOptions apiv1.AgentOptions,
 	logger boshlog.Logger,
 	cfg config.Config,
 ) Factory {
+	var hotAttachDir string
+
+	if cfg.Disks.HotAttach {
+		hotAttachDir = cfg.Disks.HotAttachDir
+	}
+
 	return Factory{
-		dkrClient: dkrClient,
-		uuidGen:   uuidGen,
+		dkrClient:      dkrClient,
+		uuidGen:        uuidGen,
+		hotAttacher:    NewHotAttacher(hotAttachDir, dkrClient, runner),
+		ephemeralDisks: NewEphemeralDisks(dkrClient, bdisk.NewLoopFiles(cfg.Disks.LoopDir, runner)),
+		lxcfs:          NewLXCFS(runner),
+		daemonInfo:     &daemonInfo{},
+		metaStore:      NewMetadataStore(cfg.VMs.MetadataDir, runner),
 
 		agentOptions: agentOptions,
 
@@ -51,21 +77,23 @@ func NewFactory(
 
 func (f Factory) Create(agentID apiv1.AgentID, stemcell bstem.Stemcell,
 	cloudProps apiv1.VMCloudProps, networks apiv1.Networks,
-	diskCIDs []apiv1.DiskCID, env apiv1.VMEnv) (VM, error) {
+	diskCIDs []apiv1.DiskCID, env apiv1.VMEnv) (VM, apiv1.Networks, error) {
 
 	var vmProps Props
 
 	err := cloudProps.As(&vmProps)
 	if err != nil {
-		return Container{}, bosherr.WrapError(err, "Unmarshaling VM properties")
+		return Container{}, nil, bosherr.WrapError(err, "Unmarshaling VM properties")
 	}
 
-	startContainersWithSystemD := f.Config.StartContainersWithSystemD
-	if vmProps.ForceStartWithSystemD {
-		startContainersWithSystemD = true
+	err = vmProps.Validate()
+	if err != nil {
+		return Container{}, nil, bosherr.WrapError(err, "Validating VM properties")
 	}
-	if vmProps.ForceStartWithoutSystemD {
-		startContainersWithSystemD = false
+
+	startContainersWithSystemD, err := f.startWithSystemD(stemcell, vmProps)
+	if err != nil {
+		return Container{}, nil, err
 	}
 
 	lxcfsEnabled := f.Config.EnableLXCFSSupport
@@ -76,54 +104,102 @@ func (f Factory) Create(agentID apiv1.AgentID, stemcell bstem.Stemcell,
 		lxcfsEnabled = false
 	}
 
-	networkInitBashCmd, netConfig, err := NewNetworks(f.dkrClient, f.uuidGen, networks).Enable()
+	security := f.Config.Security
+	if vmProps.Security != nil {
+		security = *vmProps.Security
+	}
+
+	daemon, err := f.daemon()
+	if err != nil {
+		return Container{}, nil, err
+	}
+
+	err = f.checkDaemonLimits(daemon, vmProps, security, startContainersWithSystemD)
 	if err != nil {
-		return nil, bosherr.WrapError(err, "Enabling networks")
+		return Container{}, nil, err
+	}
+
+	netConfig, dkrNetNames, err := NewNetworks(f.dkrClient, f.uuidGen, networks).Enable()
+	if err != nil {
+		return nil, nil, bosherr.WrapError(err, "Enabling networks")
 	}
 
 	idStr, err := f.uuidGen.Generate()
 	if err != nil {
-		return nil, bosherr.WrapError(err, "Generating container ID")
+		return nil, nil, bosherr.WrapError(err, "Generating container ID")
 	}
 
 	idStr = "c-" + idStr
 
 	id := apiv1.NewVMCID(idStr)
 
+	// Only the default gateway network's endpoint is set at creation, others are connected afterwards
+	netConfig, additionalEndPtConfigs := splitNetworkSettings(netConfig)
+
+	labels, err := groupLabels(env)
+	if err != nil {
+		return Container{}, nil, err
+	}
+
 	containerConfig := &dkrcont.Config{
+		Hostname:     vmHostname(agentID, id),
 		Image:        stemcell.ID().AsString(),
-		ExposedPorts: map[dkrnat.Port]struct{}{}, // todo what ports?
+		ExposedPorts: map[dkrnat.Port]struct{}{},
 		Env:          []string{"reschedule:on-node-failure"},
+		Labels:       labels,
 	}
 
 	// Umount Docker's bind-mounted /etc/resolv.conf, /etc/hosts, and /etc/hostname
 	// so the BOSH agent and BOSH DNS can manage these files freely.
 	// After unmounting /etc/resolv.conf, write a new one with DNS servers from
 	// the network spec so that processes have working DNS resolution before the
-	// BOSH agent takes over.
-	preStartCommands := []string{
-		`umount /etc/resolv.conf`,
-		`umount /etc/hosts`,
-		`umount /etc/hostname`,
-		networkInitBashCmd,
+	// BOSH agent takes over. /etc/hosts is seeded with the instance's hostname.
+	var preStartCommands []string
+
+	resolvConf, err := resolvConfProps(networks, vmProps.ResolvConf)
+	if err != nil {
+		return Container{}, nil, err
+	}
+
+	// Rootless containers cannot unmount them; files are written in place instead
+	if !daemon.Rootless {
+		preStartCommands = append(preStartCommands, []string{
+			`umount /etc/resolv.conf`,
+			`umount /etc/hosts`,
+			`umount /etc/hostname`,
+		}...)
+	}
+
+	preStartCommands = append(preStartCommands, populateEtcHosts(containerConfig.Hostname, netConfig.EndpointsConfig))
+	preStartCommands = append(preStartCommands, networkRoutesCmds(networks)...)
+
+	preStartCommands = append(preStartCommands, []string{
 		`rm -rf /var/vcap/data/sys`,
 		`mkdir -p /var/vcap/data/sys`,
 		`mkdir -p /var/vcap/store`,
 		"sed -i 's/chronyc/# chronyc/g' /var/vcap/bosh/bin/sync-time",
-	}
+	}...)
 
 	var startContainerCommands []string
 
 	if startContainersWithSystemD {
+		if len(resolvConf.Options) > 0 {
+			f.logger.Warn(f.logTag, "Skipping 'resolv_conf.options' since systemd-resolved manages /etc/resolv.conf")
+		}
+
 		preStartCommands = append(preStartCommands, []string{
 			`ln -sf /run/systemd/resolve/stub-resolv.conf /etc/resolv.conf`,
+			populateResolvedConf(resolvConf),
 			`rm -rf /etc/sv/{ssh,cron}`,
 			`rm -rf /etc/service/{ssh,cron}`,
 		}...)
 
 		startContainerCommands = append(preStartCommands, `exec /sbin/init`)
+
+		// systemd ignores SIGTERM as PID 1 and shuts down on SIGRTMIN+3 instead
+		containerConfig.StopSignal = systemdStopSignal
 	} else {
-		preStartCommands = append(preStartCommands, []string{populateResolveConf(networks)}...)
+		preStartCommands = append(preStartCommands, []string{populateResolveConf(networks, resolvConf)}...)
 
 		startContainerCommands = append(preStartCommands, `exec env -i /usr/sbin/runsvdir-start`)
 	}
@@ -133,7 +209,7 @@ func (f Factory) Create(agentID apiv1.AgentID, stemcell bstem.Stemcell,
 	if len(diskCIDs) > 0 {
 		node, err := f.possiblyFindNodeWithDisk(diskCIDs[0])
 		if err != nil {
-			return Container{}, bosherr.WrapError(err, "Finding node for disk")
+			return Container{}, nil, bosherr.WrapError(err, "Finding node for disk")
 		}
 
 		if len(node) > 0 {
@@ -143,8 +219,12 @@ func (f Factory) Create(agentID apiv1.AgentID, stemcell bstem.Stemcell,
 		}
 	}
 
-	vmProps.HostConfig.Privileged = true      //nolint:staticcheck
-	vmProps.HostConfig.PublishAllPorts = true //nolint:staticcheck
+	applySecurity(&vmProps.HostConfig, security)
+
+	vmProps.HostConfig.PublishAllPorts = f.Config.PublishAllPortsOrDefault() //nolint:staticcheck
+	if vmProps.PublishAll != nil {
+		vmProps.HostConfig.PublishAllPorts = *vmProps.PublishAll //nolint:staticcheck
+	}
 
 	if startContainersWithSystemD {
 		// systemd requires access to the host cgroup hierarchy, especially with cgroups v2.
@@ -155,34 +235,96 @@ func (f Factory) Create(agentID apiv1.AgentID, stemcell bstem.Stemcell,
 		containerConfig.ExposedPorts[dkrnat.Port(port)] = struct{}{}
 	}
 
+	hostPortBindings, err := vmProps.HostPortBindings()
+	if err != nil {
+		return Container{}, nil, err
+	}
+
+	vmProps.PortBindings = mergePortBindings(vmProps.PortBindings, hostPortBindings) //nolint:staticcheck
+
+	vmProps.PortBindings, err = vipPortBindings(networks, vmProps.ExposedPorts, vmProps.PortBindings) //nolint:staticcheck
+	if err != nil {
+		return Container{}, nil, bosherr.WrapError(err, "Publishing ports on vip networks")
+	}
+
+	// Bound ports are published even when they are not listed in 'ports'
+	for port := range vmProps.PortBindings {
+		containerConfig.ExposedPorts[port] = struct{}{}
+	}
+
+	err = f.checkPortConflicts(vmProps.PortBindings)
+	if err != nil {
+		return Container{}, nil, err
+	}
+
 	vmProps = f.cleanMounts(vmProps)
 
 	binds := []string{
 		fmt.Sprintf("%s:/var/vcap/data/", EphemeralDiskCID{id}.AsString()),
-		"/lib/modules:/usr/lib/modules", // make host kernel modules accessible
 	}
 
-	if cgroupBind(startContainersWithSystemD, f.Config.MountCgroupfs) {
-		binds = append(binds, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+	// Host paths below are owned by the real root user
+	if !daemon.Rootless {
+		binds = append(binds, "/lib/modules:/usr/lib/modules") // make host kernel modules accessible
+
+		mountCgroupfs := daemon.MountsCgroupfs()
+		if f.Config.MountCgroupfs != nil {
+			mountCgroupfs = *f.Config.MountCgroupfs
+		}
+
+		if cgroupBind(startContainersWithSystemD, mountCgroupfs) {
+			binds = append(binds, "/sys/fs/cgroup:/sys/fs/cgroup:rw")
+		}
+
+		if lxcfsEnabled {
+			lxcfsBinds, err := f.lxcfs.Binds()
+			if err != nil {
+				return Container{}, nil, err
+			}
+
+			if len(lxcfsBinds) == 0 {
+				f.logger.Warn(f.logTag, "Skipping LXCFS support since '%s' is empty on the Docker host", LXCFSDir)
+			}
+
+			binds = append(binds, lxcfsBinds...)
+		}
+	} else if lxcfsEnabled {
+		f.logger.Warn(f.logTag, "Skipping LXCFS support with a rootless Docker daemon")
+	}
+
+	if vmProps.EphemeralDisk.TypeOrDefault() == EphemeralDiskTypeLoop && vmProps.EphemeralDisk.Size > 0 {
+		if len(f.Config.Disks.LoopDir) == 0 {
+			return Container{}, nil, bosherr.Error("Enforcing ephemeral disk size requires 'disks.loop_dir' to be configured")
+		}
+	}
+
+	err = f.ephemeralDisks.Create(id, vmProps.EphemeralDisk)
+	if err != nil {
+		return Container{}, nil, err
 	}
 
-	if lxcfsEnabled {
-		binds = append(binds, "/var/lib/lxcfs/proc/meminfo:/proc/meminfo:rw")
+	if f.hotAttacher.Enabled() {
+		err = f.hotAttacher.Prepare(id)
+		if err != nil {
+			f.cleanUpEphemeralDisk(id)
+			return Container{}, nil, err
+		}
+
+		binds = append(binds, f.hotAttacher.Bind(id))
 	}
 
 	vmProps.HostConfig.Binds = binds //nolint:staticcheck
 
 	f.logger.Debug(f.logTag, "Creating container %#v, host %#v", containerConfig, &vmProps.HostConfig)
 
-	netConfig, additionalEndPtConfigs := splitNetworkSettings(netConfig)
-
 	vmProps.Platform.OS = "linux"           //nolint:staticcheck
 	vmProps.Platform.Architecture = "amd64" //nolint:staticcheck
 
 	container, err := f.dkrClient.ContainerCreate(
 		context.TODO(), containerConfig, &vmProps.HostConfig, netConfig, &vmProps.Platform, id.AsString())
 	if err != nil {
-		return Container{}, bosherr.WrapError(err, "Creating container")
+		f.cleanUpEphemeralDisk(id)
+		return Container{}, nil, bosherr.WrapError(err, "Creating container")
 	}
 
 	f.logger.Debug(f.logTag,
@@ -191,44 +333,341 @@ func (f Factory) Create(agentID apiv1.AgentID, stemcell bstem.Stemcell,
 	for name, endPtConfig := range additionalEndPtConfigs {
 		err := f.dkrClient.NetworkConnect(context.TODO(), name, id.AsString(), endPtConfig)
 		if err != nil {
-			return Container{}, bosherr.WrapErrorf(err, "Connecting container to network '%s'", name)
+			return Container{}, nil, bosherr.WrapErrorf(err, "Connecting container to network '%s'", name)
 		}
 	}
 
 	err = f.dkrClient.ContainerStart(context.TODO(), id.AsString(), dkrcont.StartOptions{})
 	if err != nil {
-		f.cleanUpContainer(container)
-		return Container{}, bosherr.WrapError(err, "Starting container")
+		f.cleanUpContainer(id)
+		return Container{}, nil, bosherr.WrapError(err, "Starting container")
+	}
+
+	// Dynamic networks only learn their addresses once the container is connected
+	networks, err = f.assignedNetworks(id, networks, dkrNetNames)
+	if err != nil {
+		f.cleanUpContainer(id)
+		return Container{}, nil, err
 	}
 
 	agentEnv := apiv1.AgentEnvFactory{}.ForVM(agentID, id, networks, env, f.agentOptions)
 	agentEnv.AttachSystemDisk(apiv1.NewDiskHintFromString(""))
 
-	fileService := NewFileService(f.dkrClient, id, f.logger)
+	fileService := NewFileService(f.dkrClient, id, f.rootless, f.logger)
 	agentEnvService := NewFSAgentEnvService(fileService, f.logger)
 
 	err = agentEnvService.Update(agentEnv)
 	if err != nil {
-		f.cleanUpContainer(container)
-		return Container{}, bosherr.WrapError(err, "Updating container's agent env")
+		f.cleanUpContainer(id)
+		return Container{}, nil, bosherr.WrapError(err, "Updating container's agent env")
+	}
+
+	return NewContainer(id, f.dkrClient, fileService, agentEnvService, f.hotAttacher, f.ephemeralDisks, f.metaStore, f.Config.PreservedPathsOrDefault(), f.logger), networks, nil
+}
+
+func (f Factory) assignedNetworks(id apiv1.VMCID, networks apiv1.Networks, dkrNetNames map[string]string) (apiv1.Networks, error) {
+	conf, err := f.dkrClient.ContainerInspect(context.TODO(), id.AsString())
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Inspecting container networks")
+	}
+
+	var endpoints map[string]*dkrnet.EndpointSettings
+
+	if conf.NetworkSettings != nil {
+		endpoints = conf.NetworkSettings.Networks
+	}
+
+	assigned, err := assignedNetworks(networks, dkrNetNames, endpoints)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Reading assigned network addresses")
 	}
 
-	return NewContainer(id, f.dkrClient, agentEnvService, f.logger), nil
+	return assigned, nil
 }
 
 func (f Factory) Find(id apiv1.VMCID) (VM, error) {
-	fileService := NewFileService(f.dkrClient, id, f.logger)
+	fileService := NewFileService(f.dkrClient, id, f.rootless, f.logger)
 	agentEnvService := NewFSAgentEnvService(fileService, f.logger)
-	return NewContainer(id, f.dkrClient, agentEnvService, f.logger), nil
+	return NewContainer(id, f.dkrClient, fileService, agentEnvService, f.hotAttacher, f.ephemeralDisks, f.metaStore, f.Config.PreservedPathsOrDefault(), f.logger), nil
 }
 
-func (f Factory) cleanUpContainer(container dkrcont.CreateResponse) {
+// daemonInfo is shared by copies of Factory so that the Docker daemon
+// is inspected at most once per CPI invocation.
+type daemonInfo struct {
+	once   sync.Once
+	daemon Daemon
+	err    error
+}
+
+// daemon detects limits of the Docker daemon; configuration takes precedence.
+// Only paths that depend on them should call it since it inspects the daemon.
+func (f Factory) daemon() (Daemon, error) {
+	f.daemonInfo.once.Do(func() {
+		f.daemonInfo.daemon, f.daemonInfo.err = f.inspectDaemon()
+	})
+
+	return f.daemonInfo.daemon, f.daemonInfo.err
+}
+
+func (f Factory) rootless() (bool, error) {
+	daemon, err := f.daemon()
+	if err != nil {
+		return false, err
+	}
+
+	return daemon.Rootless, nil
+}
+
+func (f Factory) inspectDaemon() (Daemon, error) {
+	info, err := f.dkrClient.Info(context.TODO())
+	if err != nil {
+		return Daemon{}, bosherr.WrapError(err, "Inspecting Docker daemon")
+	}
+
+	daemon := NewDaemon(info)
+
+	if f.Config.Rootless != nil {
+		daemon.Rootless = *f.Config.Rootless
+		daemon.UsernsRemap = daemon.UsernsRemap && daemon.Rootless
+	}
+
+	return daemon, nil
+}
+
+// startWithSystemD prefers the force_* VM properties, then the CPI
+// configuration, and finally the init system of the stemcell.
+func (f Factory) startWithSystemD(stemcell bstem.Stemcell, vmProps Props) (bool, error) {
+	if vmProps.ForceStartWithoutSystemD {
+		return false, nil
+	}
+
+	if vmProps.ForceStartWithSystemD {
+		return true, nil
+	}
+
+	if f.Config.StartContainersWithSystemD != nil {
+		return *f.Config.StartContainersWithSystemD, nil
+	}
+
+	initSystem, err := f.stemcellInit(stemcell)
+	if err != nil {
+		return false, err
+	}
+
+	f.logger.Debug(f.logTag, "Detected init system '%s' of stemcell '%s'", initSystem, stemcell.ID().AsString())
+
+	return initSystem == bstem.InitSystemd, nil
+}
+
+// stemcellInit returns the init system recorded on the stemcell image,
+// or probes the image when it was not recorded (e.g. light stemcells).
+// Probe results are recorded in an image keyed by the stemcell's image ID.
+func (f Factory) stemcellInit(stemcell bstem.Stemcell) (string, error) {
+	img, err := f.dkrClient.ImageInspect(context.TODO(), stemcell.ID().AsString())
+	if err != nil {
+		return "", bosherr.WrapError(err, "Inspecting stemcell image")
+	}
+
+	if img.Config != nil {
+		if initSystem, found := img.Config.Labels[bstem.InitLabel]; found {
+			return initSystem, nil
+		}
+	}
+
+	cached, err := f.dkrClient.ImageInspect(context.TODO(), bstem.InitCacheRef(img.ID))
+	if err == nil && cached.Config != nil {
+		if initSystem, found := cached.Config.Labels[bstem.InitLabel]; found {
+			return initSystem, nil
+		}
+	} else if err != nil && !cerrdefs.IsNotFound(err) {
+		return "", bosherr.WrapError(err, "Inspecting recorded init system of stemcell")
+	}
+
+	runner := host.NewContainerRunner(f.dkrClient, stemcell.ID().AsString(), f.logger)
+
+	out, err := runner.Run(host.Cmd{Script: bstem.InitProbeScript})
+	if err != nil {
+		return "", bosherr.WrapError(err, "Detecting init system of stemcell")
+	}
+
+	initSystem := strings.TrimSpace(string(out))
+
+	if len(initSystem) > 0 {
+		err = bstem.LabelInit(f.dkrClient, img.ID, bstem.InitCacheRef(img.ID), initSystem)
+		if err != nil {
+			f.logger.Warn(f.logTag, "Unable to record init system of stemcell, skipping: %s", err)
+		}
+	}
+
+	return initSystem, nil
+}
+
+// checkDaemonLimits fails early for features a rootless daemon cannot provide
+// instead of leaving a half started container behind.
+func (f Factory) checkDaemonLimits(daemon Daemon, vmProps Props, security config.SecurityOpts, systemd bool) error {
+	if daemon.UsernsRemap && (security.Privileged || vmProps.Privileged) {
+		return bosherr.Error("Privileged containers are not supported by user namespace remapped Docker daemons")
+	}
+
+	if !daemon.Rootless {
+		return nil
+	}
+
+	if systemd {
+		return bosherr.Error("Stemcells started with systemd need the host cgroup hierarchy, " +
+			"which a rootless Docker daemon cannot provide; start them with runit instead ('force_start_without_systemd')")
+	}
+
+	if f.hotAttacher.Enabled() {
+		return bosherr.Error("Hot attaching disks requires a rootful Docker daemon; disable 'disks.hot_attach'")
+	}
+
+	// Loop files would only fail to mount once the disk is attached
+	if f.Config.Disks.EnforceSize {
+		return bosherr.Error("Loop backed persistent disks require a rootful Docker daemon; disable 'disks.enforce_size'")
+	}
+
+	if vmProps.EphemeralDisk.TypeOrDefault() == EphemeralDiskTypeLoop && vmProps.EphemeralDisk.Size > 0 {
+		return bosherr.Error("Loop backed ephemeral disks require a rootful Docker daemon; use 'ephemeral_disk.type: tmpfs'")
+	}
+
+	return nil
+}
+
+// List returns VMs along with metadata set by the Director.
+func (f Factory) List(filter MetadataFilter) ([]Summary, error) {
+	metas, err := f.metaStore.List()
+	if err != nil {
+		return nil, err
+	}
+
+	vmIDs, err := f.cpiContainerIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []Summary
+
+	for _, id := range vmIDs {
+		if filter.Matches(metas[id]) {
+			summaries = append(summaries, Summary{ID: id, Metadata: metas[id]})
+		}
+	}
+
+	return summaries, nil
+}
+
+// PruneMetadata deletes metadata of containers that were removed outside of
+// the CPI. Metadata is listed before containers: records are only saved for
+// existing containers, so a record without a container listed afterwards is
+// stale even while other VMs are being created.
+func (f Factory) PruneMetadata() ([]apiv1.VMCID, error) {
+	metas, err := f.metaStore.List()
+	if err != nil {
+		return nil, err
+	}
+
+	vmIDs, err := f.cpiContainerIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range vmIDs {
+		delete(metas, id)
+	}
+
+	var pruned []apiv1.VMCID
+
+	for id := range metas {
+		f.logger.Debug(f.logTag, "Deleting metadata of missing VM '%s'", id)
+
+		err := f.metaStore.Delete(id)
+		if err != nil {
+			return nil, err
+		}
+
+		pruned = append(pruned, id)
+	}
+
+	sort.Slice(pruned, func(i, j int) bool { return pruned[i].AsString() < pruned[j].AsString() })
+
+	return pruned, nil
+}
+
+func (f Factory) cpiContainerIDs() ([]apiv1.VMCID, error) {
+	containers, err := f.dkrClient.ContainerList(context.TODO(), dkrcont.ListOptions{All: true})
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing containers")
+	}
+
+	var vmIDs []apiv1.VMCID
+
+	for _, cont := range containers {
+		// Only report containers created by the CPI
+		name := cpiContainerName(cont)
+		if len(name) > 0 {
+			vmIDs = append(vmIDs, apiv1.NewVMCID(name))
+		}
+	}
+
+	return vmIDs, nil
+}
+
+func (f Factory) checkPortConflicts(bindings dkrnat.PortMap) error {
+	if len(bindings) == 0 {
+		return nil
+	}
+
+	listOpts := dkrcont.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", "c-")),
+	}
+
+	containers, err := f.dkrClient.ContainerList(context.TODO(), listOpts)
+	if err != nil {
+		return bosherr.WrapError(err, "Listing containers with published ports")
+	}
+
+	// Stopped containers only report the ports they will publish on start
+	for i, cont := range containers {
+		if cont.State == "running" {
+			continue
+		}
+
+		contJSON, err := f.dkrClient.ContainerInspect(context.TODO(), cont.ID)
+		if err != nil {
+			if dkrclient.IsErrNotFound(err) {
+				continue
+			}
+
+			return bosherr.WrapErrorf(err, "Inspecting container '%s' for published ports", cont.ID)
+		}
+
+		if contJSON.HostConfig != nil {
+			containers[i].Ports = configuredPorts(contJSON.HostConfig.PortBindings)
+		}
+	}
+
+	return portConflicts(bindings, containers)
+}
+
+func (f Factory) cleanUpContainer(id apiv1.VMCID) {
 	// todo be more resilient at removal see Container#Delete()
 	rmOpts := dkrcont.RemoveOptions{Force: true}
 
-	err := f.dkrClient.ContainerRemove(context.TODO(), container.ID, rmOpts)
+	err := f.dkrClient.ContainerRemove(context.TODO(), id.AsString(), rmOpts)
 	if err != nil {
-		f.logger.Error(f.logTag, "Failed destroying container '%s': %s", container.ID, err.Error())
+		f.logger.Error(f.logTag, "Failed destroying container '%s': %s", id.AsString(), err.Error())
+		return
+	}
+
+	f.cleanUpEphemeralDisk(id)
+}
+
+func (f Factory) cleanUpEphemeralDisk(id apiv1.VMCID) {
+	err := f.ephemeralDisks.Delete(id)
+	if err != nil {
+		f.logger.Error(f.logTag, "Failed destroying ephemeral disk of '%s': %s", id.AsString(), err.Error())
 	}
 }
 
@@ -254,21 +693,6 @@ func (f Factory) possiblyFindNodeWithDisk(diskID apiv1.DiskCID) (string, error)
 	return "", nil
 }
 
-func populateResolveConf(networks apiv1.Networks) string {
-	var nameserverEntries []string
-	for _, network := range networks {
-		for _, dnsServer := range network.DNS() {
-			nameserverEntries = append(nameserverEntries, fmt.Sprintf(`"nameserver %s"`, dnsServer))
-		}
-	}
-
-	if len(nameserverEntries) == 0 {
-		return ":" // no-op bash command
-	}
-
-	return fmt.Sprintf(`printf '%%s\n' %s > /etc/resolv.conf`, strings.Join(nameserverEntries, " "))
-}
-
 // cgroupBind reports whether the /sys/fs/cgroup bind mount should be added to the
 // container. On cgroup-v2 hosts the bind lets systemd manage service cgroups via
 // the host hierarchy. On cgroup-v1 hosts it must be omitted because the shared
@@ -1,8 +1,11 @@
 package vm
 
 import (
+	dkrcont "github.com/docker/docker/api/types/container"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/config"
 )
 
 var _ = Describe("Factory", func() {
@@ -19,4 +22,81 @@ var _ = Describe("Factory", func() {
 			Expect(cgroupBind(true, false)).To(BeFalse())
 		})
 	})
+
+	Describe("startWithSystemD", func() {
+		enabled, disabled := true, false
+
+		It("prefers the force_* VM properties", func() {
+			factory := Factory{Config: config.Config{StartContainersWithSystemD: &disabled}}
+			Expect(factory.startWithSystemD(nil, Props{ForceStartWithSystemD: true})).To(BeTrue())
+
+			factory = Factory{Config: config.Config{StartContainersWithSystemD: &enabled}}
+			Expect(factory.startWithSystemD(nil, Props{ForceStartWithoutSystemD: true})).To(BeFalse())
+		})
+
+		It("uses the configured value over detection", func() {
+			factory := Factory{Config: config.Config{StartContainersWithSystemD: &enabled}}
+			Expect(factory.startWithSystemD(nil, Props{})).To(BeTrue())
+		})
+	})
+
+	Describe("checkDaemonLimits", func() {
+		It("returns error for loop backed persistent disks with a rootless daemon", func() {
+			factory := Factory{Config: config.Config{Disks: config.DisksOpts{EnforceSize: true, LoopDir: "/var/lib/disks"}}}
+
+			err := factory.checkDaemonLimits(Daemon{Rootless: true}, Props{}, config.SecurityOpts{}, false)
+			Expect(err).To(MatchError(ContainSubstring("Loop backed persistent disks require a rootful Docker daemon")))
+
+			Expect(factory.checkDaemonLimits(Daemon{}, Props{}, config.SecurityOpts{}, false)).To(Succeed())
+		})
+	})
+
+	Describe("applySecurity", func() {
+		It("adds the default capabilities and runs unconfined by default", func() {
+			hostConfig := dkrcont.HostConfig{}
+			applySecurity(&hostConfig, config.SecurityOpts{})
+
+			Expect(hostConfig.Privileged).To(BeFalse())
+			Expect([]string(hostConfig.CapAdd)).To(Equal(config.DefaultCapabilities))
+			Expect(hostConfig.SecurityOpt).To(Equal([]string{"apparmor=unconfined"}))
+		})
+
+		It("adds seccomp, apparmor and no-new-privileges options", func() {
+			hostConfig := dkrcont.HostConfig{}
+			applySecurity(&hostConfig, config.SecurityOpts{
+				Capabilities:    []string{"NET_ADMIN"},
+				Seccomp:         "unconfined",
+				AppArmor:        "bosh-stemcell",
+				NoNewPrivileges: true,
+			})
+
+			Expect([]string(hostConfig.CapAdd)).To(Equal([]string{"NET_ADMIN"}))
+			Expect(hostConfig.SecurityOpt).To(Equal([]string{
+				"apparmor=bosh-stemcell", "seccomp=unconfined", "no-new-privileges=true"}))
+		})
+
+		It("keeps capabilities and options set in cloud properties", func() {
+			hostConfig := dkrcont.HostConfig{
+				CapAdd:      []string{"SYS_TIME"},
+				SecurityOpt: []string{"apparmor:docker-default"},
+			}
+			applySecurity(&hostConfig, config.SecurityOpts{Capabilities: []string{"NET_ADMIN"}})
+
+			Expect([]string(hostConfig.CapAdd)).To(Equal([]string{"NET_ADMIN", "SYS_TIME"}))
+			Expect(hostConfig.SecurityOpt).To(Equal([]string{"apparmor:docker-default"}))
+		})
+
+		It("runs privileged when opted in", func() {
+			hostConfig := dkrcont.HostConfig{}
+			applySecurity(&hostConfig, config.SecurityOpts{Privileged: true})
+			Expect(hostConfig.Privileged).To(BeTrue())
+			Expect(hostConfig.CapAdd).To(BeEmpty())
+			Expect(hostConfig.SecurityOpt).To(BeEmpty())
+
+			hostConfig = dkrcont.HostConfig{Privileged: true}
+			applySecurity(&hostConfig, config.SecurityOpts{})
+			Expect(hostConfig.Privileged).To(BeTrue())
+			Expect(hostConfig.SecurityOpt).To(BeEmpty())
+		})
+	})
 })
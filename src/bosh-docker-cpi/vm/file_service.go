@@ -27,6 +27,12 @@ const execTimeout = 5 * time.Minute
 type FileService interface {
 	Upload(string, []byte) error
 	Download(string) ([]byte, error)
+
+	// DownloadArchive returns a tar archive of the given absolute paths,
+	// skipping paths that do not exist
+	DownloadArchive([]string) ([]byte, error)
+	// UploadArchive extracts a tar archive produced by DownloadArchive
+	UploadArchive([]byte) error
 }
 
 // DockerExecClient is the subset of the Docker API used by fileService for
@@ -42,6 +48,7 @@ var _ DockerExecClient = (*dkrclient.Client)(nil)
 type fileService struct {
 	dkrClient DockerExecClient
 	vmCID     apiv1.VMCID
+	rootless  func() (bool, error)
 
 	logTag string
 	logger boshlog.Logger
@@ -52,14 +59,19 @@ type fileService struct {
 // tar streaming to avoid the Docker 29.5.x containerd-snapshotter parent-escape
 // check that rejects the Ubuntu Noble stemcell's
 // /etc/resolv.conf -> ../run/systemd/resolve/stub-resolv.conf symlink.
+// With a rootless daemon uploaded files are owned by the container's root
+// user since other owners may not be mapped into its user namespace; rootless
+// is only called when uploading files since it may inspect the daemon.
 func NewFileService(
 	dkrClient DockerExecClient,
 	vmCID apiv1.VMCID,
+	rootless func() (bool, error),
 	logger boshlog.Logger,
 ) FileService {
 	return &fileService{
 		dkrClient: dkrClient,
 		vmCID:     vmCID,
+		rootless:  rootless,
 
 		logTag: "vm.fileService",
 		logger: logger,
@@ -251,6 +263,11 @@ func (s *fileService) Upload(destinationPath string, contents []byte) error {
 	// header name relative.
 	tarPath := strings.TrimLeft(cleanedDest, "/")
 
+	args, err := s.extractArgs()
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Uploading '%s'", destinationPath)
+	}
+
 	// Stream the tar archive through an io.Pipe to avoid buffering the full
 	// archive (header + contents + end-of-archive padding) before sending.
 	pr, pw := io.Pipe()
@@ -280,12 +297,126 @@ func (s *fileService) Upload(destinationPath string, contents []byte) error {
 		pw.CloseWithError(tw.Close())
 	}()
 
-	if err := s.dockerExecWithStdin(pr, "tar", "-x", "-f", "-", "-C", "/"); err != nil {
+	if err := s.dockerExecWithStdin(pr, args...); err != nil {
 		return bosherr.WrapErrorf(err, "Uploading '%s'", destinationPath)
 	}
 	return nil
 }
 
+// DownloadArchive packs files and directories at sourcePaths into a single tar
+// archive by running "tar -c -C /" inside the container. Entries are stored
+// relative to "/" with owners and modes intact so that UploadArchive can put
+// them back in place. Missing paths are skipped ("--ignore-failed-read") since
+// the agent only writes most of its state once it has done some work.
+func (s *fileService) DownloadArchive(sourcePaths []string) ([]byte, error) {
+	args := []string{"tar", "-c", "-f", "-", "-C", "/", "--ignore-failed-read", "--"}
+
+	for _, sourcePath := range sourcePaths {
+		if !isCleanAbsPath(sourcePath) {
+			return nil, bosherr.Errorf("sourcePath must be a clean absolute path, got '%s'", sourcePath)
+		}
+		args = append(args, strings.TrimLeft(sourcePath, "/"))
+	}
+
+	out, err := s.dockerExecOutput(args...)
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Archiving '%s'", strings.Join(sourcePaths, "', '"))
+	}
+
+	return out, nil
+}
+
+// UploadArchive extracts an archive produced by DownloadArchive relative to
+// "/" inside the container, recreating missing parent directories. Owners are
+// restored even with a rootless daemon since they were read from a container
+// of the same user namespace, e.g. vcap owning agent state.
+func (s *fileService) UploadArchive(archive []byte) error {
+	args := []string{"tar", "-x", "-f", "-", "-C", "/", "--same-owner"}
+
+	if err := s.dockerExecWithStdin(bytes.NewReader(archive), args...); err != nil {
+		return bosherr.WrapError(err, "Extracting archive")
+	}
+	return nil
+}
+
+// extractArgs returns the tar command extracting uploaded files relative to "/".
+func (s *fileService) extractArgs() ([]string, error) {
+	args := []string{"tar", "-x", "-f", "-", "-C", "/"}
+
+	rootless, err := s.rootless()
+	if err != nil {
+		return nil, err
+	}
+
+	if rootless {
+		args = append(args, "--no-same-owner")
+	}
+
+	return args, nil
+}
+
+func isCleanAbsPath(p string) bool {
+	return path.IsAbs(p) && p != "/" && path.Clean(p) == p
+}
+
+// dockerExecOutput runs args inside the container and returns its stdout.
+// Stderr is included in the error when the command exits with a non-zero
+// status. Unlike Download, the whole output is buffered, so it is meant for
+// small archives such as agent state.
+func (s *fileService) dockerExecOutput(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	execProcess, err := s.dkrClient.ContainerExecCreate(ctx, s.vmCID.AsString(), container.ExecOptions{
+		Cmd:          args,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Creating docker exec")
+	}
+
+	resp, err := s.dkrClient.ContainerExecAttach(ctx, execProcess.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Attaching to docker exec")
+	}
+	defer resp.Close()
+
+	if dl, ok := ctx.Deadline(); ok {
+		if err := resp.Conn.SetDeadline(dl); err != nil {
+			return nil, bosherr.WrapErrorf(err, "Setting deadline on exec connection")
+		}
+	}
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	if _, err := stdcopy.StdCopy(&stdoutBuf, &stderrBuf, resp.Reader); err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading exec output")
+	}
+
+	for {
+		inspectResp, err := s.dkrClient.ContainerExecInspect(ctx, execProcess.ID)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Inspecting docker exec response")
+		}
+
+		if inspectResp.Running {
+			select {
+			case <-ctx.Done():
+				return nil, bosherr.WrapError(ctx.Err(), "Exec polling interrupted")
+			case <-time.After(50 * time.Millisecond):
+			}
+			continue
+		}
+
+		if inspectResp.ExitCode != 0 {
+			return nil, bosherr.Errorf("%v failed [exit status %d]: %s", args, inspectResp.ExitCode, stderrBuf.String())
+		}
+
+		return stdoutBuf.Bytes(), nil
+	}
+}
+
 // dockerExecWithStdin runs args inside the container, feeding stdin from the
 // provided reader. It captures stderr and includes it in the error when the
 // command exits with a non-zero status. The exec deadline is propagated to the
@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net"
 	"time"
@@ -137,6 +138,10 @@ func buildFramedTarHeader(hdr *tar.Header, content []byte) []byte {
 	return framed.Bytes()
 }
 
+func notRootless() (bool, error) { return false, nil }
+
+func rootless() (bool, error) { return true, nil }
+
 var _ = Describe("FileService", func() {
 	var (
 		stub   *stubDockerExecClient
@@ -156,7 +161,7 @@ var _ = Describe("FileService", func() {
 	Describe("Download", func() {
 		DescribeTable("returns an error for invalid sourcePaths",
 			func(p string) {
-				svc := NewFileService(stub, vmCID, logger)
+				svc := NewFileService(stub, vmCID, notRootless, logger)
 				_, err := svc.Download(p)
 				Expect(err).To(HaveOccurred())
 			},
@@ -187,7 +192,7 @@ var _ = Describe("FileService", func() {
 				return container.ExecInspect{Running: false, ExitCode: 0}, nil
 			}
 
-			svc := NewFileService(stub, vmCID, logger)
+			svc := NewFileService(stub, vmCID, notRootless, logger)
 			got, err := svc.Download("/etc/file.json")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(got).To(Equal(fileContent))
@@ -210,7 +215,7 @@ var _ = Describe("FileService", func() {
 				return container.ExecInspect{Running: false, ExitCode: 1}, nil
 			}
 
-			svc := NewFileService(stub, vmCID, logger)
+			svc := NewFileService(stub, vmCID, notRootless, logger)
 			_, err := svc.Download("/missing/file")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("exit status 1"))
@@ -237,7 +242,7 @@ var _ = Describe("FileService", func() {
 				return container.ExecInspect{Running: false, ExitCode: 0}, nil
 			}
 
-			svc := NewFileService(stub, vmCID, logger)
+			svc := NewFileService(stub, vmCID, notRootless, logger)
 			_, err := svc.Download("/etc/file.json")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("not a regular file"))
@@ -265,7 +270,7 @@ var _ = Describe("FileService", func() {
 				return container.ExecInspect{Running: false, ExitCode: 0}, nil
 			}
 
-			svc := NewFileService(stub, vmCID, logger)
+			svc := NewFileService(stub, vmCID, notRootless, logger)
 			_, err := svc.Download("/etc/file.json")
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("unexpected name"))
@@ -275,7 +280,7 @@ var _ = Describe("FileService", func() {
 
 	Describe("Upload", func() {
 		It("returns an error when destinationPath is invalid", func() {
-			svc := NewFileService(stub, vmCID, logger)
+			svc := NewFileService(stub, vmCID, notRootless, logger)
 			for _, badPath := range []string{"", "/", "relative/path", "/etc/foo/.."} {
 				err := svc.Upload(badPath, []byte("data"))
 				Expect(err).To(HaveOccurred(), "expected error for path: %q", badPath)
@@ -311,7 +316,7 @@ var _ = Describe("FileService", func() {
 				return container.ExecInspect{Running: false, ExitCode: 0}, nil
 			}
 
-			svc := NewFileService(stub, vmCID, logger)
+			svc := NewFileService(stub, vmCID, notRootless, logger)
 			err := svc.Upload(destPath, fileContent)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -353,11 +358,174 @@ var _ = Describe("FileService", func() {
 				return container.ExecInspect{Running: false, ExitCode: 2}, nil
 			}
 
-			svc := NewFileService(stub, vmCID, logger)
+			svc := NewFileService(stub, vmCID, notRootless, logger)
 			err := svc.Upload("/var/vcap/settings.json", []byte("data"))
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("exit status 2"))
 			Expect(err.Error()).To(ContainSubstring(errMsg))
 		})
+
+		It("does not restore owners with a rootless daemon", func() {
+			stub.execCreateFn = func(_ context.Context, _ string, opts container.ExecOptions) (container.ExecCreateResponse, error) {
+				Expect(opts.Cmd).To(Equal([]string{"tar", "-x", "-f", "-", "-C", "/", "--no-same-owner"}))
+				return container.ExecCreateResponse{ID: "exec-id"}, nil
+			}
+			stub.execAttachFn = func(_ context.Context, _ string, _ container.ExecAttachOptions) (types.HijackedResponse, error) {
+				tc := newTestConn()
+				go func() {
+					defer GinkgoRecover()
+					_, err := io.Copy(io.Discard, tc.wr)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(tc.rw.Close()).To(Succeed())
+				}()
+				return types.NewHijackedResponse(tc, ""), nil
+			}
+			stub.execInspectFn = func(_ context.Context, _ string) (container.ExecInspect, error) {
+				return container.ExecInspect{Running: false, ExitCode: 0}, nil
+			}
+
+			svc := NewFileService(stub, vmCID, rootless, logger)
+			Expect(svc.Upload("/var/vcap/bosh/settings.json", []byte("data"))).To(Succeed())
+		})
+
+		It("returns error without running tar when the daemon cannot be inspected", func() {
+			stub.execCreateFn = func(_ context.Context, _ string, _ container.ExecOptions) (container.ExecCreateResponse, error) {
+				Fail("should not exec")
+				return container.ExecCreateResponse{}, nil
+			}
+
+			svc := NewFileService(stub, vmCID, func() (bool, error) { return false, errors.New("fake-err") }, logger)
+			Expect(svc.Upload("/var/vcap/bosh/settings.json", []byte("data"))).To(MatchError(ContainSubstring("fake-err")))
+		})
+	})
+
+	Describe("DownloadArchive", func() {
+		It("returns an error for invalid sourcePaths", func() {
+			svc := NewFileService(stub, vmCID, notRootless, logger)
+			for _, badPath := range []string{"", "/", "var/vcap/bosh", "/var/vcap/../bosh", "/var/vcap/"} {
+				_, err := svc.DownloadArchive([]string{"/var/vcap/instance", badPath})
+				Expect(err).To(HaveOccurred(), "expected error for path: %q", badPath)
+				Expect(err.Error()).To(ContainSubstring("clean absolute path"), "for path: %q", badPath)
+			}
+		})
+
+		It("returns the tar stream of all paths relative to the root", func() {
+			framedTar := buildFramedTar("var/vcap/instance/dns/records.json", []byte("records"))
+
+			stub.execCreateFn = func(_ context.Context, _ string, opts container.ExecOptions) (container.ExecCreateResponse, error) {
+				Expect(opts.Cmd).To(Equal([]string{
+					"tar", "-c", "-f", "-", "-C", "/", "--ignore-failed-read", "--",
+					"var/vcap/bosh/spec.json", "var/vcap/instance",
+				}))
+				return container.ExecCreateResponse{ID: "exec-id"}, nil
+			}
+			stub.execAttachFn = func(_ context.Context, _ string, _ container.ExecAttachOptions) (types.HijackedResponse, error) {
+				tc := newTestConn()
+				go func() {
+					defer GinkgoRecover()
+					_, err := tc.rw.Write(framedTar)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(tc.rw.Close()).To(Succeed())
+				}()
+				return types.NewHijackedResponse(tc, ""), nil
+			}
+			stub.execInspectFn = func(_ context.Context, _ string) (container.ExecInspect, error) {
+				return container.ExecInspect{Running: false, ExitCode: 0}, nil
+			}
+
+			svc := NewFileService(stub, vmCID, notRootless, logger)
+			archive, err := svc.DownloadArchive([]string{"/var/vcap/bosh/spec.json", "/var/vcap/instance"})
+			Expect(err).NotTo(HaveOccurred())
+
+			tr := tar.NewReader(bytes.NewReader(archive))
+			hdr, err := tr.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hdr.Name).To(Equal("var/vcap/instance/dns/records.json"))
+			body, err := io.ReadAll(tr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(body).To(Equal([]byte("records")))
+		})
+
+		It("includes the tar command's stderr in the error when the exit code is non-zero", func() {
+			errMsg := "tar: Exiting with failure status due to previous errors\n"
+
+			stub.execAttachFn = func(_ context.Context, _ string, _ container.ExecAttachOptions) (types.HijackedResponse, error) {
+				tc := newTestConn()
+				go func() {
+					defer GinkgoRecover()
+					_, err := tc.rw.Write(buildFramedStderr(errMsg))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(tc.rw.Close()).To(Succeed())
+				}()
+				return types.NewHijackedResponse(tc, ""), nil
+			}
+			stub.execInspectFn = func(_ context.Context, _ string) (container.ExecInspect, error) {
+				return container.ExecInspect{Running: false, ExitCode: 2}, nil
+			}
+
+			svc := NewFileService(stub, vmCID, notRootless, logger)
+			_, err := svc.DownloadArchive([]string{"/var/vcap/instance"})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("exit status 2"))
+			Expect(err.Error()).To(ContainSubstring(errMsg))
+		})
+	})
+
+	Describe("UploadArchive", func() {
+		It("streams the archive unchanged to tar extracting at the root", func() {
+			archive := []byte("archive-bytes")
+
+			stub.execCreateFn = func(_ context.Context, _ string, opts container.ExecOptions) (container.ExecCreateResponse, error) {
+				Expect(opts.Cmd).To(Equal([]string{"tar", "-x", "-f", "-", "-C", "/", "--same-owner"}))
+				Expect(opts.AttachStdin).To(BeTrue())
+				return container.ExecCreateResponse{ID: "exec-id"}, nil
+			}
+
+			receivedCh := make(chan []byte, 1)
+			stub.execAttachFn = func(_ context.Context, _ string, _ container.ExecAttachOptions) (types.HijackedResponse, error) {
+				tc := newTestConn()
+				go func() {
+					defer GinkgoRecover()
+					data, err := io.ReadAll(tc.wr)
+					Expect(err).NotTo(HaveOccurred())
+					receivedCh <- data
+					Expect(tc.rw.Close()).To(Succeed())
+				}()
+				return types.NewHijackedResponse(tc, ""), nil
+			}
+			stub.execInspectFn = func(_ context.Context, _ string) (container.ExecInspect, error) {
+				return container.ExecInspect{Running: false, ExitCode: 0}, nil
+			}
+
+			svc := NewFileService(stub, vmCID, notRootless, logger)
+			Expect(svc.UploadArchive(archive)).To(Succeed())
+			Expect(<-receivedCh).To(Equal(archive))
+		})
+
+		It("restores owners of agent state with a rootless daemon without inspecting it", func() {
+			stub.execCreateFn = func(_ context.Context, _ string, opts container.ExecOptions) (container.ExecCreateResponse, error) {
+				Expect(opts.Cmd).To(Equal([]string{"tar", "-x", "-f", "-", "-C", "/", "--same-owner"}))
+				return container.ExecCreateResponse{ID: "exec-id"}, nil
+			}
+			stub.execAttachFn = func(_ context.Context, _ string, _ container.ExecAttachOptions) (types.HijackedResponse, error) {
+				tc := newTestConn()
+				go func() {
+					defer GinkgoRecover()
+					_, err := io.Copy(io.Discard, tc.wr)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(tc.rw.Close()).To(Succeed())
+				}()
+				return types.NewHijackedResponse(tc, ""), nil
+			}
+			stub.execInspectFn = func(_ context.Context, _ string) (container.ExecInspect, error) {
+				return container.ExecInspect{Running: false, ExitCode: 0}, nil
+			}
+
+			svc := NewFileService(stub, vmCID, func() (bool, error) {
+				Fail("should not inspect the daemon")
+				return true, nil
+			}, logger)
+			Expect(svc.UploadArchive([]byte("archive-bytes"))).To(Succeed())
+		})
 	})
 })
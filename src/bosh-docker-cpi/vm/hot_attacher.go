@@ -0,0 +1,260 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	cerrdefs "github.com/containerd/errdefs"
+	dkrcont "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+
+	dkrclient "github.com/docker/docker/client"
+
+	"bosh-docker-cpi/host"
+)
+
+// errNotHotAttachable is returned for volumes whose host path is not known,
+// e.g. ones provided by volume plugins, and for volumes Docker mounts itself.
+var errNotHotAttachable = errors.New("Volume cannot be hot attached")
+
+// hotAttachedToLabel marks holder containers with the VM their volume is
+// hot attached to.
+const hotAttachedToLabel = "bosh-docker-cpi.hot-attached-to"
+
+// HotAttacher mounts persistent disks into running containers. Each container
+// gets a shared directory on the Docker host bound to PersistentDiskMountDir
+// with rslave propagation; volumes mounted into that directory on the host
+// show up inside the container without restarting it.
+//
+// Mounts are made in the mount namespace of the host's PID 1 which is
+// expected to be shared with the Docker daemon. Docker does not see these
+// mounts, so each hot attached volume is also referenced by a holder
+// container that is never started; Docker refuses to remove or prune
+// volumes that containers reference.
+type HotAttacher struct {
+	dir string
+
+	dkrClient *dkrclient.Client
+	runner    host.Runner
+}
+
+func NewHotAttacher(dir string, dkrClient *dkrclient.Client, runner host.Runner) HotAttacher {
+	return HotAttacher{dir: dir, dkrClient: dkrClient, runner: runner}
+}
+
+func (h HotAttacher) Enabled() bool { return len(h.dir) > 0 }
+
+func (h HotAttacher) vmDir(id apiv1.VMCID) string {
+	return filepath.Join(h.dir, id.AsString())
+}
+
+// Bind returns the bind that exposes hot attached disks to the container.
+func (h HotAttacher) Bind(id apiv1.VMCID) string {
+	return h.vmDir(id) + ":" + PersistentDiskMountDir + ":rslave"
+}
+
+// Prepare turns the container's directory into a shared mount
+// so that mounts made into it propagate to the container.
+func (h HotAttacher) Prepare(id apiv1.VMCID) error {
+	cmd := host.Cmd{
+		Script: `set -e
+nsenter -t 1 -m -- sh -c '
+  mkdir -p "$DIR"
+  mountpoint -q "$DIR" || mount --bind "$DIR" "$DIR"
+  mount --make-shared "$DIR"
+'`,
+		Env:        []string{"DIR=" + h.vmDir(id)},
+		PIDHost:    true,
+		Privileged: true,
+	}
+
+	_, err := h.runner.Run(cmd)
+	if err != nil {
+		return bosherr.WrapError(err, "Preparing hot attach directory")
+	}
+
+	return nil
+}
+
+// Mount mounts a local volume into the container's directory.
+func (h HotAttacher) Mount(id apiv1.VMCID, diskID apiv1.DiskCID) error {
+	vol, err := h.dkrClient.VolumeInspect(context.TODO(), diskID.AsString())
+	if err != nil {
+		return bosherr.WrapError(err, "Inspecting volume")
+	}
+
+	source, err := hotAttachSource(vol)
+	if err != nil {
+		return err
+	}
+
+	err = h.hold(id, diskID)
+	if err != nil {
+		return err
+	}
+
+	cmd := host.Cmd{
+		Script: `set -e
+nsenter -t 1 -m -- sh -c '
+  mkdir -p "$TARGET"
+  mountpoint -q "$TARGET" || mount --bind "$SOURCE" "$TARGET"
+'`,
+		Env:        []string{"TARGET=" + h.diskDir(id, diskID), "SOURCE=" + source},
+		PIDHost:    true,
+		Privileged: true,
+	}
+
+	_, err = h.runner.Run(cmd)
+	if err != nil {
+		h.release(diskID) //nolint:errcheck
+		return bosherr.WrapErrorf(err, "Mounting volume '%s'", diskID.AsString())
+	}
+
+	return nil
+}
+
+// Unmount succeeds if the disk is not mounted.
+func (h HotAttacher) Unmount(id apiv1.VMCID, diskID apiv1.DiskCID) error {
+	cmd := host.Cmd{
+		Script: `set -e
+nsenter -t 1 -m -- sh -c '
+  if mountpoint -q "$TARGET"; then umount "$TARGET" || umount -l "$TARGET"; fi
+  rmdir "$TARGET" 2>/dev/null || true
+'`,
+		Env:        []string{"TARGET=" + h.diskDir(id, diskID)},
+		PIDHost:    true,
+		Privileged: true,
+	}
+
+	_, err := h.runner.Run(cmd)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Unmounting volume '%s'", diskID.AsString())
+	}
+
+	return h.release(diskID)
+}
+
+// Cleanup unmounts leftover disks and removes the container's directory.
+func (h HotAttacher) Cleanup(id apiv1.VMCID) error {
+	cmd := host.Cmd{
+		Script: `nsenter -t 1 -m -- sh -c '
+  [ -d "$DIR" ] || exit 0
+  umount -R -l "$DIR" 2>/dev/null
+  find "$DIR" -mindepth 1 -maxdepth 1 -type d -exec rmdir {} +
+  rmdir "$DIR"
+'`,
+		Env:        []string{"DIR=" + h.vmDir(id)},
+		PIDHost:    true,
+		Privileged: true,
+	}
+
+	_, err := h.runner.Run(cmd)
+	if err != nil {
+		return bosherr.WrapError(err, "Cleaning up hot attach directory")
+	}
+
+	listOpts := dkrcont.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", hotAttachedToLabel+"="+id.AsString())),
+	}
+
+	holders, err := h.dkrClient.ContainerList(context.TODO(), listOpts)
+	if err != nil {
+		return bosherr.WrapError(err, "Listing holder containers")
+	}
+
+	for _, holder := range holders {
+		err := h.removeHolder(holder.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hold creates the holder container referencing the volume. It uses the
+// VM's image since that is known to be present.
+func (h HotAttacher) hold(id apiv1.VMCID, diskID apiv1.DiskCID) error {
+	conf, err := h.dkrClient.ContainerInspect(context.TODO(), id.AsString())
+	if err != nil {
+		return bosherr.WrapError(err, "Inspecting container")
+	}
+
+	contConfig := &dkrcont.Config{
+		Image:  conf.Image,
+		Cmd:    []string{"true"},
+		Labels: map[string]string{hotAttachedToLabel: id.AsString()},
+	}
+
+	hostConfig := &dkrcont.HostConfig{
+		Binds:       []string{diskID.AsString() + ":/" + diskID.AsString()},
+		NetworkMode: "none",
+	}
+
+	_, err = h.dkrClient.ContainerCreate(context.TODO(), contConfig, hostConfig, nil, nil, holderName(diskID))
+	if err != nil {
+		// Left over from an earlier attempt
+		if cerrdefs.IsConflict(err) {
+			return nil
+		}
+
+		return bosherr.WrapErrorf(err, "Creating holder container for volume '%s'", diskID.AsString())
+	}
+
+	return nil
+}
+
+// release succeeds if the volume has no holder container.
+func (h HotAttacher) release(diskID apiv1.DiskCID) error {
+	return h.removeHolder(holderName(diskID))
+}
+
+func (h HotAttacher) removeHolder(nameOrID string) error {
+	// Volumes are kept; only the reference to them goes away
+	err := h.dkrClient.ContainerRemove(context.TODO(), nameOrID, dkrcont.RemoveOptions{})
+	if err != nil && !cerrdefs.IsNotFound(err) {
+		return bosherr.WrapErrorf(err, "Removing holder container '%s'", nameOrID)
+	}
+
+	return nil
+}
+
+func holderName(diskID apiv1.DiskCID) string {
+	return "hot-attach-" + diskID.AsString()
+}
+
+func (h HotAttacher) diskDir(id apiv1.VMCID, diskID apiv1.DiskCID) string {
+	return filepath.Join(h.vmDir(id), diskID.AsString())
+}
+
+// hotAttachSource returns the host directory of a plain local volume.
+// Volumes with options (e.g. loop backed ones) are only mounted by Docker
+// while containers use them; mounted behind Docker's back they would look
+// unused, so Docker would let them be removed or copied while in use.
+func hotAttachSource(vol volume.Volume) (string, error) {
+	if vol.Driver != "local" || len(vol.Options) > 0 || len(vol.Mountpoint) == 0 {
+		return "", errNotHotAttachable
+	}
+
+	return vol.Mountpoint, nil
+}
+
+// hasHotAttachBind reports whether the container was created with the bind
+// that hot attached disks propagate through.
+func hasHotAttachBind(binds []string) bool {
+	for _, bind := range binds {
+		pieces := strings.Split(bind, ":")
+		if len(pieces) == 3 && path.Clean(pieces[1]) == PersistentDiskMountDir && pieces[2] == "rslave" {
+			return true
+		}
+	}
+
+	return false
+}
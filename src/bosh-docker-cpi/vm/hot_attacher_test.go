@@ -0,0 +1,132 @@
+package vm_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	dkrclient "github.com/docker/docker/client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/host/hostfakes"
+	. "bosh-docker-cpi/vm"
+)
+
+// dockerRoundTripper answers Docker API requests with canned responses.
+type dockerRoundTripper struct {
+	requests  []string
+	responses map[string]string
+}
+
+func (t *dockerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := req.URL.Path[strings.Index(req.URL.Path[1:], "/")+1:] // without API version
+	t.requests = append(t.requests, req.Method+" "+path)
+
+	body, found := t.responses[req.Method+" "+path]
+	if !found {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{"message":"not found"}`))}, nil
+	}
+
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+var _ = Describe("HotAttacher", func() {
+	var (
+		runner      *hostfakes.FakeRunner
+		transport   *dockerRoundTripper
+		hotAttacher HotAttacher
+		vmCID       apiv1.VMCID
+	)
+
+	BeforeEach(func() {
+		runner = &hostfakes.FakeRunner{}
+		transport = &dockerRoundTripper{responses: map[string]string{"GET /containers/json": "[]"}}
+
+		dkrClient, err := dkrclient.NewClientWithOpts(
+			dkrclient.WithHTTPClient(&http.Client{Transport: transport}),
+			dkrclient.WithVersion("1.44"),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		hotAttacher = NewHotAttacher("/var/lib/hot-attach", dkrClient, runner)
+		vmCID = apiv1.NewVMCID("c-123")
+	})
+
+	It("is disabled without a directory", func() {
+		Expect(hotAttacher.Enabled()).To(BeTrue())
+		Expect(NewHotAttacher("", nil, runner).Enabled()).To(BeFalse())
+	})
+
+	Describe("Bind", func() {
+		It("binds the container directory to the persistent disk dir with rslave propagation", func() {
+			Expect(hotAttacher.Bind(vmCID)).To(Equal("/var/lib/hot-attach/c-123:/warden-cpi-dev:rslave"))
+		})
+	})
+
+	Describe("Prepare", func() {
+		It("makes the container directory a shared mount in the host mount namespace", func() {
+			Expect(hotAttacher.Prepare(vmCID)).To(Succeed())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.PIDHost).To(BeTrue())
+			Expect(cmd.Privileged).To(BeTrue())
+			Expect(cmd.Script).To(ContainSubstring("nsenter -t 1 -m"))
+			Expect(cmd.Script).To(ContainSubstring(`mount --make-shared "$DIR"`))
+			Expect(cmd.Env).To(Equal([]string{"DIR=/var/lib/hot-attach/c-123"}))
+		})
+
+		It("returns error if preparing fails", func() {
+			runner.RunReturns(nil, errors.New("fake-err"))
+
+			Expect(hotAttacher.Prepare(vmCID)).To(MatchError(ContainSubstring("fake-err")))
+		})
+	})
+
+	Describe("Unmount", func() {
+		It("unmounts the disk from the container directory", func() {
+			Expect(hotAttacher.Unmount(vmCID, apiv1.NewDiskCID("vol-abc"))).To(Succeed())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.PIDHost).To(BeTrue())
+			Expect(cmd.Script).To(ContainSubstring(`umount "$TARGET"`))
+			Expect(cmd.Env).To(Equal([]string{"TARGET=/var/lib/hot-attach/c-123/vol-abc"}))
+		})
+
+		It("removes the holder container keeping the volume", func() {
+			transport.responses["DELETE /containers/hot-attach-vol-abc"] = ""
+
+			Expect(hotAttacher.Unmount(vmCID, apiv1.NewDiskCID("vol-abc"))).To(Succeed())
+			Expect(transport.requests).To(Equal([]string{"DELETE /containers/hot-attach-vol-abc"}))
+		})
+
+		It("succeeds if the volume has no holder container", func() {
+			Expect(hotAttacher.Unmount(vmCID, apiv1.NewDiskCID("vol-abc"))).To(Succeed())
+		})
+	})
+
+	Describe("Cleanup", func() {
+		It("unmounts leftovers and removes the container directory", func() {
+			Expect(hotAttacher.Cleanup(vmCID)).To(Succeed())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Script).To(ContainSubstring(`umount -R -l "$DIR"`))
+			Expect(cmd.Env).To(Equal([]string{"DIR=/var/lib/hot-attach/c-123"}))
+		})
+
+		It("removes holder containers of volumes hot attached to the container", func() {
+			transport.responses["GET /containers/json"] = `[{"Id":"holder-1"},{"Id":"holder-2"}]`
+			transport.responses["DELETE /containers/holder-1"] = ""
+			transport.responses["DELETE /containers/holder-2"] = ""
+
+			Expect(hotAttacher.Cleanup(vmCID)).To(Succeed())
+			Expect(transport.requests).To(Equal([]string{
+				"GET /containers/json",
+				"DELETE /containers/holder-1",
+				"DELETE /containers/holder-2",
+			}))
+		})
+	})
+})
@@ -13,7 +13,7 @@ import (
 
 type Creator interface {
 	Create(apiv1.AgentID, bstem.Stemcell, apiv1.VMCloudProps,
-		apiv1.Networks, []apiv1.DiskCID, apiv1.VMEnv) (VM, error)
+		apiv1.Networks, []apiv1.DiskCID, apiv1.VMEnv) (VM, apiv1.Networks, error)
 }
 
 var _ Creator = Factory{}
@@ -26,6 +26,23 @@ type Finder interface {
 
 var _ Finder = Factory{}
 
+//counterfeiter:generate . Lister
+
+type Lister interface {
+	List(MetadataFilter) ([]Summary, error)
+}
+
+var _ Lister = Factory{}
+
+//counterfeiter:generate . MetadataPruner
+
+// MetadataPruner removes metadata of VMs that were removed outside of the CPI.
+type MetadataPruner interface {
+	PruneMetadata() ([]apiv1.VMCID, error)
+}
+
+var _ MetadataPruner = Factory{}
+
 //counterfeiter:generate . VM
 
 type VM interface {
@@ -33,7 +50,11 @@ type VM interface {
 
 	Delete() error
 	Exists() (bool, error)
+	Reboot() error
+
+	SetMetadata(apiv1.VMMeta) error
 
+	DiskIDs() ([]apiv1.DiskCID, error)
 	AttachDisk(bdisk.Disk) (apiv1.DiskHint, error)
 	DetachDisk(bdisk.Disk) error
 }
@@ -0,0 +1,79 @@
+package vm
+
+import (
+	"strings"
+	"sync"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-docker-cpi/host"
+)
+
+// LXCFSDir is where LXCFS mounts its file system on the Docker host.
+const LXCFSDir = "/var/lib/lxcfs"
+
+// lxcfsPaths are the container paths LXCFS can virtualize, relative to LXCFSDir.
+// Which of them are available depends on the LXCFS version and kernel.
+var lxcfsPaths = []string{
+	"/proc/cpuinfo",
+	"/proc/diskstats",
+	"/proc/loadavg",
+	"/proc/meminfo",
+	"/proc/slabinfo",
+	"/proc/stat",
+	"/proc/swaps",
+	"/proc/uptime",
+	"/sys/devices/system/cpu",
+}
+
+// LXCFS binds files virtualized by LXCFS over their /proc and /sys counterparts
+// so that tools inside containers report the container's resources.
+type LXCFS struct {
+	runner host.Runner
+	probe  *lxcfsProbe
+}
+
+// lxcfsProbe is shared by copies of LXCFS so that the Docker host
+// is probed at most once per CPI invocation.
+type lxcfsProbe struct {
+	once  sync.Once
+	binds []string
+	err   error
+}
+
+func NewLXCFS(runner host.Runner) LXCFS {
+	return LXCFS{runner: runner, probe: &lxcfsProbe{}}
+}
+
+// Binds probes the Docker host for the paths LXCFS exposes and returns binds
+// for the ones found.
+func (l LXCFS) Binds() ([]string, error) {
+	l.probe.once.Do(func() {
+		l.probe.binds, l.probe.err = l.probeBinds()
+	})
+
+	return l.probe.binds, l.probe.err
+}
+
+func (l LXCFS) probeBinds() ([]string, error) {
+	cmd := host.Cmd{
+		Script: `for p in $PATHS; do
+  if [ -e "$DIR$p" ]; then echo "$p"; fi
+done`,
+		Env:   []string{"DIR=" + LXCFSDir, "PATHS=" + strings.Join(lxcfsPaths, " ")},
+		Binds: []string{LXCFSDir + ":" + LXCFSDir + ":ro"},
+	}
+
+	out, err := l.runner.Run(cmd)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Probing LXCFS files")
+	}
+
+	var binds []string
+
+	for _, p := range strings.Fields(string(out)) {
+		binds = append(binds, LXCFSDir+p+":"+p+":rw")
+	}
+
+	return binds, nil
+}
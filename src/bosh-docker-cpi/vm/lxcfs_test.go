@@ -0,0 +1,72 @@
+package vm_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/host/hostfakes"
+	. "bosh-docker-cpi/vm"
+)
+
+var _ = Describe("LXCFS", func() {
+	var (
+		runner *hostfakes.FakeRunner
+		lxcfs  LXCFS
+	)
+
+	BeforeEach(func() {
+		runner = &hostfakes.FakeRunner{}
+		lxcfs = NewLXCFS(runner)
+	})
+
+	Describe("Binds", func() {
+		It("probes the LXCFS directory on the Docker host", func() {
+			_, err := lxcfs.Binds()
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Binds).To(Equal([]string{"/var/lib/lxcfs:/var/lib/lxcfs:ro"}))
+			Expect(cmd.Privileged).To(BeFalse())
+			Expect(cmd.Env).To(ContainElement("DIR=/var/lib/lxcfs"))
+			Expect(cmd.Env).To(ContainElement(ContainSubstring("/proc/cpuinfo")))
+			Expect(cmd.Env).To(ContainElement(ContainSubstring("/sys/devices/system/cpu")))
+		})
+
+		It("binds the files found over their /proc and /sys counterparts", func() {
+			runner.RunReturns([]byte("/proc/meminfo\n/proc/uptime\n/sys/devices/system/cpu\n"), nil)
+
+			binds, err := lxcfs.Binds()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(binds).To(Equal([]string{
+				"/var/lib/lxcfs/proc/meminfo:/proc/meminfo:rw",
+				"/var/lib/lxcfs/proc/uptime:/proc/uptime:rw",
+				"/var/lib/lxcfs/sys/devices/system/cpu:/sys/devices/system/cpu:rw",
+			}))
+		})
+
+		It("probes the Docker host only once", func() {
+			runner.RunReturns([]byte("/proc/meminfo\n"), nil)
+
+			copied := lxcfs
+
+			Expect(lxcfs.Binds()).To(HaveLen(1))
+			Expect(copied.Binds()).To(HaveLen(1))
+			Expect(runner.RunCallCount()).To(Equal(1))
+		})
+
+		It("returns no binds when LXCFS is not running", func() {
+			runner.RunReturns([]byte(""), nil)
+
+			Expect(lxcfs.Binds()).To(BeEmpty())
+		})
+
+		It("returns error if probing fails", func() {
+			runner.RunReturns(nil, errors.New("fake-err"))
+
+			_, err := lxcfs.Binds()
+			Expect(err).To(MatchError(ContainSubstring("fake-err")))
+		})
+	})
+})
@@ -0,0 +1,132 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+)
+
+// metadataLabelPrefix namespaces container labels holding VM metadata so that
+// containers can be found with e.g. 'docker ps --filter label=bosh.deployment=cf'.
+const metadataLabelPrefix = "bosh."
+
+// volatileMetadataKeys are regenerated by the Director on every
+// set_vm_metadata call and do not help finding VMs.
+var volatileMetadataKeys = map[string]struct{}{
+	"created_at": {},
+}
+
+// MetadataValues converts VM metadata (deployment, job, index, director, tags...)
+// into string values.
+func MetadataValues(meta apiv1.VMMeta) (map[string]string, error) {
+	bytes, err := json.Marshal(meta)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshaling VM metadata")
+	}
+
+	var kvs map[string]interface{}
+
+	err = json.Unmarshal(bytes, &kvs)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshaling VM metadata")
+	}
+
+	values := map[string]string{}
+
+	for key, val := range kvs {
+		if _, found := volatileMetadataKeys[key]; found || val == nil {
+			continue
+		}
+
+		if str, ok := val.(string); ok {
+			values[key] = str
+		} else {
+			values[key] = fmt.Sprintf("%v", val)
+		}
+	}
+
+	return values, nil
+}
+
+// MetadataFilter selects VMs whose metadata has all of the given key/values.
+type MetadataFilter map[string]string
+
+// NewMetadataFilter parses a comma separated list of key=value pairs,
+// e.g. 'deployment=cf,job=router,index=0'. An empty string matches all VMs.
+func NewMetadataFilter(str string) (MetadataFilter, error) {
+	filter := MetadataFilter{}
+
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+
+		pieces := strings.SplitN(pair, "=", 2)
+		if len(pieces) != 2 || len(pieces[0]) == 0 {
+			return nil, bosherr.Errorf("Expected filter '%s' to be in key=value format", pair)
+		}
+
+		filter[pieces[0]] = pieces[1]
+	}
+
+	return filter, nil
+}
+
+// Matches reports whether metadata has all of the filter's key/values.
+func (f MetadataFilter) Matches(meta map[string]string) bool {
+	for key, val := range f {
+		if actualVal, found := meta[key]; !found || actualVal != val {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Summary describes a listed VM along with its metadata.
+type Summary struct {
+	ID       apiv1.VMCID
+	Metadata map[string]string
+}
+
+// metadataLabels converts recorded VM metadata into container labels.
+func metadataLabels(meta map[string]string) map[string]string {
+	labels := map[string]string{}
+
+	for key, val := range meta {
+		labels[metadataLabelPrefix+key] = val
+	}
+
+	return labels
+}
+
+// groupLabels labels a new container with the instance's group
+// (<director>-<deployment>-<instance group>) from the VM env since
+// the Director only sends metadata once the container exists.
+func groupLabels(env apiv1.VMEnv) (map[string]string, error) {
+	bytes, err := json.Marshal(env)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Marshaling VM env")
+	}
+
+	var spec struct {
+		BOSH struct {
+			Group string `json:"group"`
+		} `json:"bosh"`
+	}
+
+	err = json.Unmarshal(bytes, &spec)
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Unmarshaling VM env")
+	}
+
+	if len(spec.BOSH.Group) == 0 {
+		return nil, nil
+	}
+
+	return map[string]string{metadataLabelPrefix + "group": spec.BOSH.Group}, nil
+}
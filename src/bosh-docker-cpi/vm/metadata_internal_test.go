@@ -0,0 +1,34 @@
+package vm
+
+import (
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metadata labels", func() {
+	Describe("metadataLabels", func() {
+		It("prefixes metadata keys", func() {
+			labels := metadataLabels(map[string]string{"deployment": "cf", "index": "0"})
+			Expect(labels).To(Equal(map[string]string{"bosh.deployment": "cf", "bosh.index": "0"}))
+		})
+	})
+
+	Describe("groupLabels", func() {
+		It("labels the instance's group from the VM env", func() {
+			env := apiv1.NewVMEnv(map[string]interface{}{
+				"bosh": map[string]interface{}{"group": "bosh-cf-router", "password": "secret"},
+			})
+
+			labels, err := groupLabels(env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(labels).To(Equal(map[string]string{"bosh.group": "bosh-cf-router"}))
+		})
+
+		It("returns no labels when the VM env has no group", func() {
+			labels, err := groupLabels(apiv1.NewVMEnv(map[string]interface{}{}))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(labels).To(BeEmpty())
+		})
+	})
+})
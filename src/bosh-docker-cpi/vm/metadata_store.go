@@ -0,0 +1,66 @@
+package vm
+
+import (
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+
+	"bosh-docker-cpi/host"
+)
+
+// MetadataStore keeps VM metadata in records named after VM CIDs on the
+// Docker host, since containers cannot be relabeled without recreating them.
+// All operations are no-ops when no directory is configured.
+type MetadataStore struct {
+	records host.RecordStore
+}
+
+func NewMetadataStore(dir string, runner host.Runner) MetadataStore {
+	return MetadataStore{records: host.NewRecordStore(dir, runner)}
+}
+
+func (s MetadataStore) Enabled() bool { return s.records.Enabled() }
+
+// Save replaces the metadata of a VM.
+func (s MetadataStore) Save(id apiv1.VMCID, meta map[string]string) error {
+	err := s.records.Save(id.AsString(), meta)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Saving metadata of VM '%s'", id.AsString())
+	}
+
+	return nil
+}
+
+// Get returns the metadata of a VM, or nil when none was recorded.
+func (s MetadataStore) Get(id apiv1.VMCID) (map[string]string, error) {
+	meta, err := s.records.Get(id.AsString())
+	if err != nil {
+		return nil, bosherr.WrapErrorf(err, "Reading metadata of VM '%s'", id.AsString())
+	}
+
+	return meta, nil
+}
+
+func (s MetadataStore) Delete(id apiv1.VMCID) error {
+	err := s.records.Delete(id.AsString())
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Deleting metadata of VM '%s'", id.AsString())
+	}
+
+	return nil
+}
+
+// List returns metadata of all VMs keyed by VM CID.
+func (s MetadataStore) List() (map[apiv1.VMCID]map[string]string, error) {
+	records, err := s.records.List()
+	if err != nil {
+		return nil, bosherr.WrapError(err, "Listing VM metadata")
+	}
+
+	metas := map[apiv1.VMCID]map[string]string{}
+
+	for key, meta := range records {
+		metas[apiv1.NewVMCID(key)] = meta
+	}
+
+	return metas, nil
+}
@@ -0,0 +1,71 @@
+package vm_test
+
+import (
+	"errors"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"bosh-docker-cpi/host/hostfakes"
+	. "bosh-docker-cpi/vm"
+)
+
+var _ = Describe("MetadataStore", func() {
+	var (
+		runner *hostfakes.FakeRunner
+		store  MetadataStore
+	)
+
+	BeforeEach(func() {
+		runner = &hostfakes.FakeRunner{}
+		store = NewMetadataStore("/var/lib/vm-metadata", runner)
+	})
+
+	Describe("Save", func() {
+		It("writes metadata named after the VM", func() {
+			err := store.Save(apiv1.NewVMCID("c-123"), map[string]string{"deployment": "cf", "index": "0"})
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Env).To(ConsistOf(
+				"FILE=/var/lib/vm-metadata/c-123.json",
+				`RECORD={"deployment":"cf","index":"0"}`,
+			))
+		})
+
+		It("returns error if writing fails", func() {
+			runner.RunReturns(nil, errors.New("fake-err"))
+
+			err := store.Save(apiv1.NewVMCID("c-123"), nil)
+			Expect(err).To(MatchError(ContainSubstring("Saving metadata of VM 'c-123'")))
+			Expect(err).To(MatchError(ContainSubstring("fake-err")))
+		})
+	})
+
+	Describe("List", func() {
+		It("returns metadata keyed by VM CID", func() {
+			runner.RunReturns([]byte(
+				"c-123\t{\"deployment\":\"cf\",\"index\":\"0\"}\n"+
+					"c-456\t{\"deployment\":\"db\"}\n",
+			), nil)
+
+			metas, err := store.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metas).To(Equal(map[apiv1.VMCID]map[string]string{
+				apiv1.NewVMCID("c-123"): {"deployment": "cf", "index": "0"},
+				apiv1.NewVMCID("c-456"): {"deployment": "db"},
+			}))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("removes the record of the VM", func() {
+			err := store.Delete(apiv1.NewVMCID("c-123"))
+			Expect(err).NotTo(HaveOccurred())
+
+			cmd := runner.RunArgsForCall(0)
+			Expect(cmd.Env).To(Equal([]string{"FILE=/var/lib/vm-metadata/c-123.json"}))
+		})
+	})
+})
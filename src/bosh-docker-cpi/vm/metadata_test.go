@@ -0,0 +1,67 @@
+package vm_test
+
+import (
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "bosh-docker-cpi/vm"
+)
+
+var _ = Describe("MetadataValues", func() {
+	It("stringifies values", func() {
+		meta := apiv1.NewVMMeta(map[string]interface{}{
+			"deployment": "cf",
+			"job":        "router",
+			"index":      float64(2),
+			"director":   "bosh-lite",
+		})
+
+		values, err := MetadataValues(meta)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).To(Equal(map[string]string{
+			"deployment": "cf",
+			"job":        "router",
+			"index":      "2",
+			"director":   "bosh-lite",
+		}))
+	})
+
+	It("skips created_at since it changes on every call", func() {
+		meta := apiv1.NewVMMeta(map[string]interface{}{
+			"deployment": "cf",
+			"created_at": "2026-01-01T00:00:00Z",
+		})
+
+		values, err := MetadataValues(meta)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).To(Equal(map[string]string{"deployment": "cf"}))
+	})
+})
+
+var _ = Describe("MetadataFilter", func() {
+	It("parses key=value pairs", func() {
+		filter, err := NewMetadataFilter("deployment=cf, job=router,index=0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filter).To(Equal(MetadataFilter{"deployment": "cf", "job": "router", "index": "0"}))
+	})
+
+	It("matches everything when empty", func() {
+		filter, err := NewMetadataFilter("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filter).To(BeEmpty())
+		Expect(filter.Matches(nil)).To(BeTrue())
+	})
+
+	It("matches metadata including all key/values", func() {
+		filter := MetadataFilter{"deployment": "cf", "job": "router"}
+		Expect(filter.Matches(map[string]string{"deployment": "cf", "job": "router", "index": "0"})).To(BeTrue())
+		Expect(filter.Matches(map[string]string{"deployment": "cf", "job": "api"})).To(BeFalse())
+		Expect(filter.Matches(map[string]string{"deployment": "cf"})).To(BeFalse())
+	})
+
+	It("returns error for pairs without a value", func() {
+		_, err := NewMetadataFilter("deployment")
+		Expect(err).To(MatchError(ContainSubstring("key=value")))
+	})
+})
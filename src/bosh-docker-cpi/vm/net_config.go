@@ -1,28 +1,88 @@
 package vm
 
 import (
+	"fmt"
+	"sort"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
 	dkrnet "github.com/docker/docker/api/types/network"
 )
 
+// primaryGwPriority marks the endpoint of the network BOSH uses as default gateway
+const primaryGwPriority = 1
+
+// splitNetworkSettings separates the primary endpoint, which the container is
+// created with and which provides the default gateway, from endpoints connected
+// afterwards. Like Docker, it picks the endpoint with the highest GwPriority,
+// and the lexicographically first network name among equals.
 func splitNetworkSettings(netConf *dkrnet.NetworkingConfig) (*dkrnet.NetworkingConfig, map[string]*dkrnet.EndpointSettings) {
 	if len(netConf.EndpointsConfig) == 0 {
 		return netConf, nil
 	}
 
-	for name1, settings1 := range netConf.EndpointsConfig {
-		out1 := map[string]*dkrnet.EndpointSettings{name1: settings1}
-		out2 := map[string]*dkrnet.EndpointSettings{}
+	var names []string
+
+	for name := range netConf.EndpointsConfig {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	primary := names[0]
+
+	for _, name := range names[1:] {
+		if netConf.EndpointsConfig[name].GwPriority > netConf.EndpointsConfig[primary].GwPriority {
+			primary = name
+		}
+	}
+
+	out1 := map[string]*dkrnet.EndpointSettings{primary: netConf.EndpointsConfig[primary]}
+	out2 := map[string]*dkrnet.EndpointSettings{}
+
+	for name, settings := range netConf.EndpointsConfig {
+		if name != primary {
+			out2[name] = settings
+		}
+	}
+
+	netConf.EndpointsConfig = out1
+
+	return netConf, out2
+}
+
+// networkRoutesCmds pins the default route to the gateway of the BOSH default
+// gateway network, and routes traffic from addresses of other networks through
+// their own gateways (source based routing) so that replies leave through the
+// network requests came in on. Dynamic networks rely on Docker's routes.
+func networkRoutesCmds(networks apiv1.Networks) []string {
+	var cmds []string
+	table := 100
+
+	for _, name := range sortedNetworkNames(networks) {
+		net := networks[name]
+
+		if net.Type() == VIPNetworkType || len(net.IP()) == 0 || len(net.Gateway()) == 0 {
+			continue
+		}
+
+		family := "-4"
+		if newIPAddr(net.IP()).IsV6() {
+			family = "-6"
+		}
 
-		for name2, settings2 := range netConf.EndpointsConfig {
-			if name1 != name2 {
-				out2[name2] = settings2
-			}
+		if net.IsDefaultFor("gateway") {
+			cmds = append(cmds, fmt.Sprintf("ip %s route replace default via %s", family, net.Gateway()))
+			continue
 		}
 
-		netConf.EndpointsConfig = out1
+		table++
 
-		return netConf, out2
+		cmds = append(cmds, []string{
+			fmt.Sprintf("ip %s route replace default via %s table %d", family, net.Gateway(), table),
+			fmt.Sprintf("{ ip %s rule del from %s table %d 2>/dev/null || true; }", family, net.IP(), table),
+			fmt.Sprintf("ip %s rule add from %s table %d", family, net.IP(), table),
+		}...)
 	}
 
-	panic("Unreachable")
+	return cmds
 }
@@ -25,4 +25,41 @@ var _ = Describe("NetProps", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(props.EnableIPv6).To(BeFalse())
 	})
+
+	It("unmarshals driver options, labels and flags", func() {
+		var props NetProps
+		err := json.Unmarshal([]byte(`{
+			"name": "lan",
+			"driver": "macvlan",
+			"driver_opts": {"parent": "eth1", "macvlan_mode": "bridge"},
+			"labels": {"team": "db"},
+			"internal": true,
+			"attachable": true
+		}`), &props)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(props.DriverOpts).To(Equal(map[string]string{"parent": "eth1", "macvlan_mode": "bridge"}))
+		Expect(props.Labels).To(Equal(map[string]string{"team": "db"}))
+		Expect(props.Internal).To(BeTrue())
+		Expect(props.Attachable).To(BeTrue())
+	})
+
+	Describe("Validate", func() {
+		It("accepts an IP range and auxiliary addresses", func() {
+			var props NetProps
+			err := json.Unmarshal([]byte(`{"ip_range": "10.245.0.128/25", "auxiliary_addresses": {"dhcp": "10.245.0.2"}}`), &props)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(props.IPRange).To(Equal("10.245.0.128/25"))
+			Expect(props.AuxiliaryAddresses).To(Equal(map[string]string{"dhcp": "10.245.0.2"}))
+			Expect(props.Validate()).To(Succeed())
+		})
+
+		It("returns error when the IP range is not a CIDR", func() {
+			Expect(NetProps{IPRange: "10.245.0.128"}.Validate()).To(MatchError(ContainSubstring("Expected 'ip_range' to be a CIDR")))
+		})
+
+		It("returns error when an auxiliary address is not an IP", func() {
+			props := NetProps{AuxiliaryAddresses: map[string]string{"dhcp": "host"}}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("Expected 'auxiliary_addresses' to map names to IPs")))
+		})
+	})
 })
@@ -0,0 +1,115 @@
+package vm
+
+import (
+	"fmt"
+	gonet "net"
+	"sort"
+	"strings"
+
+	dkrnet "github.com/docker/docker/api/types/network"
+)
+
+// networkDrift lists how an existing Docker network differs from the options
+// it would be created with. Options and IPAM settings that are not requested
+// (e.g. the subnet of dynamic networks) are left to Docker and not compared.
+func networkDrift(existing dkrnet.Inspect, desired dkrnet.CreateOptions) []string {
+	var drift []string
+
+	if existing.Driver != desired.Driver {
+		drift = append(drift, fmt.Sprintf("driver: expected '%s', got '%s'", desired.Driver, existing.Driver))
+	}
+
+	if desired.EnableIPv4 != nil && *desired.EnableIPv4 != existing.EnableIPv4 {
+		drift = append(drift, fmt.Sprintf("enable_ipv4: expected %t, got %t", *desired.EnableIPv4, existing.EnableIPv4))
+	}
+
+	if desired.EnableIPv6 != nil && *desired.EnableIPv6 != existing.EnableIPv6 {
+		drift = append(drift, fmt.Sprintf("enable_ipv6: expected %t, got %t", *desired.EnableIPv6, existing.EnableIPv6))
+	}
+
+	if existing.Internal != desired.Internal {
+		drift = append(drift, fmt.Sprintf("internal: expected %t, got %t", desired.Internal, existing.Internal))
+	}
+
+	if existing.Attachable != desired.Attachable {
+		drift = append(drift, fmt.Sprintf("attachable: expected %t, got %t", desired.Attachable, existing.Attachable))
+	}
+
+	for _, key := range sortedKeys(desired.Options) {
+		if existing.Options[key] != desired.Options[key] {
+			drift = append(drift, fmt.Sprintf("option '%s': expected '%s', got '%s'",
+				key, desired.Options[key], existing.Options[key]))
+		}
+	}
+
+	for _, key := range sortedKeys(desired.Labels) {
+		if existing.Labels[key] != desired.Labels[key] {
+			drift = append(drift, fmt.Sprintf("label '%s': expected '%s', got '%s'",
+				key, desired.Labels[key], existing.Labels[key]))
+		}
+	}
+
+	if desired.IPAM != nil {
+		for _, conf := range desired.IPAM.Config {
+			drift = append(drift, ipamConfigDrift(existing.IPAM.Config, conf)...)
+		}
+	}
+
+	return drift
+}
+
+func ipamConfigDrift(existing []dkrnet.IPAMConfig, desired dkrnet.IPAMConfig) []string {
+	var subnets []string
+
+	for _, conf := range existing {
+		subnets = append(subnets, conf.Subnet)
+
+		if !sameCIDR(conf.Subnet, desired.Subnet) {
+			continue
+		}
+
+		var drift []string
+
+		if len(desired.Gateway) > 0 && len(conf.Gateway) > 0 &&
+			!gonet.ParseIP(conf.Gateway).Equal(gonet.ParseIP(desired.Gateway)) {
+			drift = append(drift, fmt.Sprintf("gateway of subnet '%s': expected '%s', got '%s'",
+				desired.Subnet, desired.Gateway, conf.Gateway))
+		}
+
+		if len(desired.IPRange) > 0 && !sameCIDR(conf.IPRange, desired.IPRange) {
+			drift = append(drift, fmt.Sprintf("ip_range of subnet '%s': expected '%s', got '%s'",
+				desired.Subnet, desired.IPRange, conf.IPRange))
+		}
+
+		return drift
+	}
+
+	if len(subnets) == 0 {
+		subnets = []string{"none"}
+	}
+
+	return []string{fmt.Sprintf("subnet: expected '%s', got '%s'", desired.Subnet, strings.Join(subnets, "', '"))}
+}
+
+func sameCIDR(a, b string) bool {
+	_, aNet, aErr := gonet.ParseCIDR(a)
+	_, bNet, bErr := gonet.ParseCIDR(b)
+
+	if aErr != nil || bErr != nil {
+		return a == b
+	}
+
+	return aNet.String() == bNet.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
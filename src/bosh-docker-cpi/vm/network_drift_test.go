@@ -0,0 +1,86 @@
+package vm
+
+import (
+	dkrnet "github.com/docker/docker/api/types/network"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("networkDrift", func() {
+	var (
+		existing dkrnet.Inspect
+		desired  dkrnet.CreateOptions
+	)
+
+	BeforeEach(func() {
+		enableIPv6 := false
+
+		existing = dkrnet.Inspect{
+			Name:    "bosh",
+			Driver:  "bridge",
+			Options: map[string]string{"com.docker.network.driver.mtu": "1500"},
+			IPAM: dkrnet.IPAM{Config: []dkrnet.IPAMConfig{
+				{Subnet: "10.245.0.0/16", Gateway: "10.245.0.1", IPRange: "10.245.128.0/17"},
+			}},
+		}
+
+		desired = dkrnet.CreateOptions{
+			Driver:     "bridge",
+			EnableIPv6: &enableIPv6,
+			IPAM: &dkrnet.IPAM{Config: []dkrnet.IPAMConfig{
+				{Subnet: "10.245.0.0/16", Gateway: "10.245.0.1", IPRange: "10.245.128.0/17"},
+			}},
+		}
+	})
+
+	It("returns nothing when the network matches", func() {
+		Expect(networkDrift(existing, desired)).To(BeEmpty())
+	})
+
+	It("ignores options and IPAM settings that are not requested", func() {
+		desired.IPAM = nil
+		desired.EnableIPv6 = nil
+		Expect(networkDrift(existing, desired)).To(BeEmpty())
+	})
+
+	It("lists driver, IPv6, flag, option and label differences", func() {
+		enableIPv6 := true
+		desired.Driver = "overlay"
+		desired.EnableIPv6 = &enableIPv6
+		desired.Internal = true
+		desired.Attachable = true
+		desired.Options = map[string]string{"com.docker.network.driver.mtu": "1450"}
+		desired.Labels = map[string]string{"team": "db"}
+
+		Expect(networkDrift(existing, desired)).To(Equal([]string{
+			"driver: expected 'overlay', got 'bridge'",
+			"enable_ipv6: expected true, got false",
+			"internal: expected true, got false",
+			"attachable: expected true, got false",
+			"option 'com.docker.network.driver.mtu': expected '1450', got '1500'",
+			"label 'team': expected 'db', got ''",
+		}))
+	})
+
+	It("lists a different subnet", func() {
+		desired.IPAM.Config[0].Subnet = "10.246.0.0/16"
+		Expect(networkDrift(existing, desired)).To(Equal([]string{
+			"subnet: expected '10.246.0.0/16', got '10.245.0.0/16'",
+		}))
+
+		existing.IPAM.Config = nil
+		Expect(networkDrift(existing, desired)).To(Equal([]string{
+			"subnet: expected '10.246.0.0/16', got 'none'",
+		}))
+	})
+
+	It("lists gateway and IP range differences of the same subnet", func() {
+		desired.IPAM.Config[0].Gateway = "10.245.0.254"
+		desired.IPAM.Config[0].IPRange = "10.245.192.0/18"
+
+		Expect(networkDrift(existing, desired)).To(Equal([]string{
+			"gateway of subnet '10.245.0.0/16': expected '10.245.0.254', got '10.245.0.1'",
+			"ip_range of subnet '10.245.0.0/16': expected '10.245.192.0/18', got '10.245.128.0/17'",
+		}))
+	})
+})
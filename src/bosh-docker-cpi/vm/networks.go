@@ -3,6 +3,8 @@ package vm
 import (
 	"context"
 	"fmt"
+	gonet "net"
+	"reflect"
 	"regexp"
 	"strings"
 
@@ -10,6 +12,7 @@ import (
 	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	boshuuid "github.com/cloudfoundry/bosh-utils/uuid"
 	dkrnet "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/versions"
 	dkrclient "github.com/docker/docker/client"
 )
 
@@ -24,6 +27,9 @@ var (
 	alreadyExistsCheck = "already exists"
 )
 
+// minIPv6OnlyAPIVersion is the first Docker API version honoring EnableIPv4
+const minIPv6OnlyAPIVersion = "1.47"
+
 type Networks struct {
 	dkrClient *dkrclient.Client
 	uuidGen   boshuuid.Generator
@@ -38,50 +44,129 @@ func NewNetworks(
 	return Networks{dkrClient, uuidGen, networks}
 }
 
-func (n Networks) Enable() (string, *dkrnet.NetworkingConfig, error) {
+// Enable creates Docker networks as necessary and returns the container's
+// networking config along with Docker network names keyed by BOSH network name.
+func (n Networks) Enable() (*dkrnet.NetworkingConfig, map[string]string, error) {
 	if len(n.networks) == 0 {
-		return "", nil, bosherr.Error("Expected exactly one network; received zero")
+		return nil, nil, bosherr.Error("Expected exactly one network; received zero")
+	}
+
+	dkrNets, err := n.dockerNetworks()
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var netConfigPairs []netConfigPair
-	onlyHasIPv6Networks := true
+	dkrNames := map[string]string{}
+
+	for _, dkrNet := range dkrNets {
+		name, err := n.enableDockerNetwork(dkrNet)
+		if err != nil {
+			return nil, nil, bosherr.WrapErrorf(err, "Enabling network '%s'", dkrNet.boshNames())
+		}
+
+		for _, pair := range dkrNet.Pairs {
+			pair.Props.Name = name
+			netConfigPairs = append(netConfigPairs, pair)
+			dkrNames[pair.Name] = name
+		}
+	}
+
+	return n.networkingConfig(netConfigPairs), dkrNames, nil
+}
+
+type netConfigPair struct {
+	Name    string // BOSH network name
+	Network apiv1.Network
+	Props   NetProps
+}
+
+// dockerNetwork holds BOSH networks sharing a Docker network, e.g. an IPv4
+// and an IPv6 network with the same 'name' make up a dual-stack network
+type dockerNetwork struct {
+	Dynamic bool
+	Pairs   []netConfigPair
+}
 
-	for name, net := range n.networks {
+func (n dockerNetwork) boshNames() string {
+	var names []string
+
+	for _, pair := range n.Pairs {
+		names = append(names, pair.Name)
+	}
+
+	return strings.Join(names, "', '")
+}
+
+func (n Networks) dockerNetworks() ([]*dockerNetwork, error) {
+	boshNames := sortedNetworkNames(n.networks)
+
+	var dkrNets []*dockerNetwork
+	dkrNetsByName := map[string]*dockerNetwork{}
+
+	for _, name := range boshNames {
+		net := n.networks[name]
 		net.SetPreconfigured()
 
-		netProps, err := n.enableSingleNetwork(net)
+		if net.Type() == VIPNetworkType {
+			continue // see vipPortBindings
+		}
+
+		netProps, err := n.netProps(net)
 		if err != nil {
-			return "", nil, bosherr.WrapErrorf(err, "Enabling network '%s'", name)
+			return nil, bosherr.WrapErrorf(err, "Enabling network '%s'", name)
 		}
 
-		netConfigPairs = append(netConfigPairs, netConfigPair{net, netProps})
+		dkrName := netProps.Name
+		pair := netConfigPair{name, net, netProps}
 
-		if !newIPAddr(net.IP()).IsV6() {
-			onlyHasIPv6Networks = false
+		if len(dkrName) == 0 {
+			dkrName = net.SubnetCIDR() // todo better name?
 		}
-	}
 
-	networkInitBashCmd := " : " // bash noop
+		dkrNet, found := dkrNetsByName[dkrName]
+		if !found {
+			dkrNet = &dockerNetwork{Dynamic: len(net.Netmask()) == 0, Pairs: []netConfigPair{pair}}
+			dkrNetsByName[dkrName] = dkrNet
+			dkrNets = append(dkrNets, dkrNet)
+			continue
+		}
 
-	if onlyHasIPv6Networks {
-		// Docker seems to add IPv4 address to the container
-		// regardless if one was requested on IPv6 interfaces; remove them.
-		// Ignore fe80:: addresses — they appear on ALL interfaces.
-		// Similarly, we ignore the loopback (::1) interfaces.
-		networkInitBashCmd = strings.Join([]string{
-			// make sure there are no tabs
-			`for DEV in $(grep -E -v "^fe80|^0{31}1" /proc/net/if_inet6 | awk '{print $6}'); do`,
-			`ip addr del $(ip -4 addr show $DEV | grep "inet" | awk '{print $2}') dev $DEV`,
-			`done`,
-		}, "\n")
+		err = dkrNet.add(pair)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Enabling network '%s' with Docker network '%s'", name, dkrName)
+		}
 	}
 
-	return networkInitBashCmd, n.networkingConfig(netConfigPairs), nil
+	return dkrNets, nil
 }
 
-type netConfigPair struct {
-	Network apiv1.Network
-	Props   NetProps
+func (n *dockerNetwork) add(pair netConfigPair) error {
+	first := n.Pairs[0]
+
+	if n.Dynamic != (len(pair.Network.Netmask()) == 0) {
+		return bosherr.Errorf("Expected network '%s' to be of the same type as network '%s'", pair.Name, first.Name)
+	}
+
+	// Subnet specific properties may differ; everything else applies to the whole Docker network
+	if !reflect.DeepEqual(first.Props.shared(), pair.Props.shared()) {
+		return bosherr.Errorf("Expected network '%s' to have the same cloud properties as network '%s' "+
+			"apart from 'ip_range', 'auxiliary_addresses' and 'resolv_conf'", pair.Name, first.Name)
+	}
+
+	if !n.Dynamic {
+		// Each container has a single endpoint per Docker network
+		for _, other := range n.Pairs {
+			if newIPAddr(other.Network.IP()).IsV6() == newIPAddr(pair.Network.IP()).IsV6() {
+				return bosherr.Errorf("Expected at most one IPv4 and one IPv6 network to share a Docker network, "+
+					"but networks '%s' and '%s' are of the same IP version", other.Name, pair.Name)
+			}
+		}
+	}
+
+	n.Pairs = append(n.Pairs, pair)
+
+	return nil
 }
 
 func (n Networks) networkingConfig(netConfigPairs []netConfigPair) *dkrnet.NetworkingConfig {
@@ -90,8 +175,17 @@ func (n Networks) networkingConfig(netConfigPairs []netConfigPair) *dkrnet.Netwo
 	}
 
 	for _, pair := range netConfigPairs {
-		endPtConfig := &dkrnet.EndpointSettings{
-			IPAMConfig: &dkrnet.EndpointIPAMConfig{},
+		// Dual-stack networks request both addresses on the same endpoint
+		endPtConfig, found := netConfig.EndpointsConfig[pair.Props.Name]
+		if !found {
+			endPtConfig = &dkrnet.EndpointSettings{
+				IPAMConfig: &dkrnet.EndpointIPAMConfig{},
+			}
+			netConfig.EndpointsConfig[pair.Props.Name] = endPtConfig
+		}
+
+		if pair.Network.IsDefaultFor("gateway") {
+			endPtConfig.GwPriority = primaryGwPriority
 		}
 
 		if newIPAddr(pair.Network.IP()).IsV6() {
@@ -99,14 +193,12 @@ func (n Networks) networkingConfig(netConfigPairs []netConfigPair) *dkrnet.Netwo
 		} else {
 			endPtConfig.IPAMConfig.IPv4Address = pair.Network.IP()
 		}
-
-		netConfig.EndpointsConfig[pair.Props.Name] = endPtConfig
 	}
 
 	return netConfig
 }
 
-func (n Networks) enableSingleNetwork(network apiv1.Network) (NetProps, error) {
+func (n Networks) netProps(network apiv1.Network) (NetProps, error) {
 	netProps := NetProps{Driver: "bridge"}
 
 	err := network.CloudProps().As(&netProps)
@@ -114,75 +206,119 @@ func (n Networks) enableSingleNetwork(network apiv1.Network) (NetProps, error) {
 		return NetProps{}, bosherr.WrapError(err, "Unmarshaling network properties")
 	}
 
+	err = netProps.Validate()
+	if err != nil {
+		return NetProps{}, bosherr.WrapError(err, "Validating network properties")
+	}
+
 	if len(network.Netmask()) == 0 {
-		netProps.Name, err = n.createDynamicNetwork(netProps)
-		if err != nil {
-			return NetProps{}, bosherr.WrapError(err, "Creating dynamic network")
+		if len(netProps.Name) == 0 {
+			// todo pick up network name?
+			return NetProps{}, bosherr.Error("Expected network to specify 'name'")
 		}
-	} else {
-		netProps.Name, err = n.createManualNetwork(netProps, network)
-		if err != nil {
-			return NetProps{}, bosherr.WrapError(err, "Creating manual network")
+
+		if len(netProps.IPRange) > 0 || len(netProps.AuxiliaryAddresses) > 0 {
+			return NetProps{}, bosherr.Error("Expected 'ip_range' and 'auxiliary_addresses' to only be set on manual networks")
 		}
 	}
 
 	return netProps, nil
 }
 
-func (n Networks) createDynamicNetwork(netProps NetProps) (string, error) {
-	if len(netProps.Name) == 0 {
-		// todo pick up network name?
-		return "", bosherr.Error("Expected network to specify 'name'")
+func (n Networks) enableDockerNetwork(dkrNet *dockerNetwork) (string, error) {
+	if dkrNet.Dynamic {
+		name, err := n.createDynamicNetwork(dkrNet.Pairs[0].Props)
+		if err != nil {
+			return "", bosherr.WrapError(err, "Creating dynamic network")
+		}
+
+		return name, nil
+	}
+
+	name, err := n.createManualNetwork(dkrNet.Pairs)
+	if err != nil {
+		return "", bosherr.WrapError(err, "Creating manual network")
 	}
 
+	return name, nil
+}
+
+func (n Networks) createDynamicNetwork(netProps NetProps) (string, error) {
 	createOpts := dkrnet.CreateOptions{
 		Driver:     netProps.Driver,
 		EnableIPv6: &netProps.EnableIPv6,
-		Internal:   false,
-		Attachable: false,
+		Internal:   netProps.Internal,
+		Attachable: netProps.Attachable,
+		Options:    netProps.DriverOpts,
+		Labels:     netProps.Labels,
 	}
 
-	_, err := n.dkrClient.NetworkCreate(context.TODO(), netProps.Name, createOpts)
+	err := n.createNetwork(netProps.Name, createOpts, netProps.Reconcile)
 	if err != nil {
-		if !strings.Contains(err.Error(), alreadyExistsCheck) {
-			return "", err
-		}
+		return "", err
 	}
 
 	return netProps.Name, nil
 }
 
-func (n Networks) createManualNetwork(netProps NetProps, network apiv1.Network) (string, error) {
+func (n Networks) createManualNetwork(pairs []netConfigPair) (string, error) {
+	netProps, network := pairs[0].Props, pairs[0].Network
 	name := netProps.Name
 
 	if len(name) == 0 {
 		name = network.SubnetCIDR() // todo better name?
 	}
 
-	enableIPv6 := netProps.EnableIPv6 || newIPAddr(network.IP()).IsV6()
+	var ipamConfigs []dkrnet.IPAMConfig
+	var subnets []string
+	var hasIPv4, hasIPv6 bool
+
+	for _, pair := range pairs {
+		ipamConfigs = append(ipamConfigs, dkrnet.IPAMConfig{
+			Subnet:     pair.Network.SubnetCIDR(),
+			IPRange:    pair.Props.IPRange,
+			Gateway:    pair.Network.Gateway(),
+			AuxAddress: pair.Props.AuxiliaryAddresses,
+		})
+
+		subnets = append(subnets, pair.Network.SubnetCIDR())
+
+		if newIPAddr(pair.Network.IP()).IsV6() {
+			hasIPv6 = true
+		} else {
+			hasIPv4 = true
+		}
+	}
+
+	enableIPv6 := netProps.EnableIPv6 || hasIPv6
 
 	createOpts := dkrnet.CreateOptions{
 		Driver:     netProps.Driver,
 		EnableIPv6: &enableIPv6,
-		Internal:   false,
-		Attachable: false,
+		Internal:   netProps.Internal,
+		Attachable: netProps.Attachable,
+		Options:    netProps.DriverOpts,
+		Labels:     netProps.Labels,
 
 		IPAM: &dkrnet.IPAM{
 			Driver: "default",
-			Config: []dkrnet.IPAMConfig{
-				{Subnet: network.SubnetCIDR()},
-			},
+			Config: ipamConfigs,
 		},
 	}
 
-	_, err := n.dkrClient.NetworkCreate(context.TODO(), name, createOpts)
-	if err != nil {
-		// Network with same name was just created
-		// todo assume equivalent to our configuration
-		if strings.Contains(err.Error(), alreadyExistsCheck) {
-			return name, nil
+	if !hasIPv4 {
+		// Older daemons ignore EnableIPv4 and add IPv4 addresses to containers regardless
+		if versions.LessThan(n.dkrClient.ClientVersion(), minIPv6OnlyAPIVersion) {
+			return "", bosherr.Errorf("Expected Docker API version to be at least '%s' for IPv6-only networks, got '%s'",
+				minIPv6OnlyAPIVersion, n.dkrClient.ClientVersion())
 		}
 
+		enableIPv4 := false
+		createOpts.EnableIPv4 = &enableIPv4
+	}
+
+	err := n.createNetwork(name, createOpts, netProps.Reconcile)
+	if err != nil {
 		matches := conflictingNetMatch.FindStringSubmatch(err.Error())
 		if len(matches) > 0 {
 			if len(matches) != 2 {
@@ -193,10 +329,10 @@ func (n Networks) createManualNetwork(netProps NetProps, network apiv1.Network)
 				return "", bosherr.WrapErrorf(err,
 					"Expected network '%s' to not have subnet '%s' "+
 						"while trying to create network '%s' with the same subnet",
-					matches[1], network.SubnetCIDR(), netProps.Name)
+					matches[1], strings.Join(subnets, "' or '"), netProps.Name)
 			}
 
-			return matches[1], nil
+			return matches[1], n.checkExistingNetwork(matches[1], pairs)
 		}
 
 		return "", err
@@ -204,3 +340,93 @@ func (n Networks) createManualNetwork(netProps NetProps, network apiv1.Network)
 
 	return name, nil
 }
+
+// createNetwork creates a Docker network unless one with the same name and
+// equivalent options exists (e.g. created for another VM). Networks that drifted
+// from their options are recreated when reconcile is set.
+func (n Networks) createNetwork(name string, createOpts dkrnet.CreateOptions, reconcile bool) error {
+	_, err := n.dkrClient.NetworkCreate(context.TODO(), name, createOpts)
+	if err == nil || !strings.Contains(err.Error(), alreadyExistsCheck) {
+		return err
+	}
+
+	existing, err := n.dkrClient.NetworkInspect(context.TODO(), name, dkrnet.InspectOptions{})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Inspecting existing network '%s'", name)
+	}
+
+	drift := networkDrift(existing, createOpts)
+	if len(drift) == 0 {
+		return nil
+	}
+
+	if !reconcile {
+		return bosherr.Errorf(
+			"Expected existing network '%s' to match its configuration: %s; "+
+				"remove the Docker network or set 'reconcile: true' in the network's cloud properties to recreate it",
+			name, strings.Join(drift, ", "))
+	}
+
+	// Fails while containers are still connected, which is safer than disconnecting them
+	err = n.dkrClient.NetworkRemove(context.TODO(), existing.ID)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Removing network '%s' to reconcile %s", name, strings.Join(drift, ", "))
+	}
+
+	_, err = n.dkrClient.NetworkCreate(context.TODO(), name, createOpts)
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Recreating network '%s'", name)
+	}
+
+	return nil
+}
+
+func (n Networks) checkExistingNetwork(name string, pairs []netConfigPair) error {
+	existing, err := n.dkrClient.NetworkInspect(context.TODO(), name, dkrnet.InspectOptions{})
+	if err != nil {
+		return bosherr.WrapErrorf(err, "Inspecting existing network '%s'", name)
+	}
+
+	return checkGateways(existing, pairs)
+}
+
+// checkGateways checks each BOSH network sharing a Docker network, so an
+// IPv6 gateway of a dual-stack network is compared with the IPv6 subnet.
+func checkGateways(existing dkrnet.Inspect, pairs []netConfigPair) error {
+	for _, pair := range pairs {
+		err := checkGateway(existing, pair.Network)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkGateway makes sure containers on an existing Docker network route
+// through the gateway from the cloud config; Docker would otherwise silently
+// keep the gateway the network was created with.
+func checkGateway(existing dkrnet.Inspect, network apiv1.Network) error {
+	gateway := gonet.ParseIP(network.Gateway())
+	if gateway == nil {
+		return nil
+	}
+
+	ip := gonet.ParseIP(network.IP())
+
+	for _, conf := range existing.IPAM.Config {
+		_, subnet, err := gonet.ParseCIDR(conf.Subnet)
+		if err != nil || !subnet.Contains(ip) || len(conf.Gateway) == 0 {
+			continue
+		}
+
+		if !gonet.ParseIP(conf.Gateway).Equal(gateway) {
+			return bosherr.Errorf(
+				"Expected existing network '%s' with subnet '%s' to have gateway '%s' "+
+					"but it has gateway '%s'; recreate the Docker network or change the gateway in the cloud config",
+				existing.Name, conf.Subnet, network.Gateway(), conf.Gateway)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package vm
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	dkrnet "github.com/docker/docker/api/types/network"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Networks", func() {
+	unmarshalNetworks := func(data string) apiv1.Networks {
+		var networks apiv1.Networks
+		Expect(json.Unmarshal([]byte(data), &networks)).To(Succeed())
+		return networks
+	}
+
+	Describe("dockerNetworks", func() {
+		It("groups an IPv4 and an IPv6 network with the same name into a dual-stack network", func() {
+			networks := unmarshalNetworks(`{
+				"v4": {"type": "manual", "ip": "10.245.0.10", "netmask": "255.255.255.0", "cloud_properties": {"name": "bosh", "ip_range": "10.245.0.128/25"}},
+				"v6": {"type": "manual", "ip": "fd00::10", "netmask": "ffff:ffff:ffff:ffff::", "cloud_properties": {"name": "bosh"}},
+				"other": {"type": "manual", "ip": "10.246.0.10", "netmask": "255.255.255.0", "cloud_properties": {}}
+			}`)
+
+			dkrNets, err := NewNetworks(nil, nil, networks).dockerNetworks()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dkrNets).To(HaveLen(2))
+			Expect(dkrNets[0].boshNames()).To(Equal("other"))
+			Expect(dkrNets[1].boshNames()).To(Equal("v4', 'v6"))
+		})
+
+		It("skips vip networks", func() {
+			networks := unmarshalNetworks(`{
+				"default": {"type": "dynamic", "cloud_properties": {"name": "bosh"}},
+				"public": {"type": "vip", "ip": "203.0.113.10", "cloud_properties": {}}
+			}`)
+
+			dkrNets, err := NewNetworks(nil, nil, networks).dockerNetworks()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dkrNets).To(HaveLen(1))
+			Expect(dkrNets[0].boshNames()).To(Equal("default"))
+		})
+
+		It("returns error when networks sharing a Docker network differ in type", func() {
+			networks := unmarshalNetworks(`{
+				"a": {"type": "dynamic", "cloud_properties": {"name": "bosh"}},
+				"b": {"type": "manual", "ip": "fd00::10", "netmask": "ffff:ffff:ffff:ffff::", "cloud_properties": {"name": "bosh"}}
+			}`)
+
+			_, err := NewNetworks(nil, nil, networks).dockerNetworks()
+			Expect(err).To(MatchError(ContainSubstring("Expected network 'b' to be of the same type as network 'a'")))
+		})
+
+		It("returns error when networks sharing a Docker network differ in shared cloud properties", func() {
+			networks := unmarshalNetworks(`{
+				"v4": {"type": "manual", "ip": "10.245.0.10", "netmask": "255.255.255.0", "cloud_properties": {"name": "bosh"}},
+				"v6": {"type": "manual", "ip": "fd00::10", "netmask": "ffff:ffff:ffff:ffff::", "cloud_properties": {"name": "bosh", "internal": true}}
+			}`)
+
+			_, err := NewNetworks(nil, nil, networks).dockerNetworks()
+			Expect(err).To(MatchError(ContainSubstring("Expected network 'v6' to have the same cloud properties as network 'v4'")))
+		})
+
+		It("returns error when networks of the same IP version share a Docker network", func() {
+			networks := unmarshalNetworks(`{
+				"a": {"type": "manual", "ip": "10.245.0.10", "netmask": "255.255.255.0", "cloud_properties": {"name": "bosh"}},
+				"b": {"type": "manual", "ip": "10.246.0.10", "netmask": "255.255.255.0", "cloud_properties": {"name": "bosh"}}
+			}`)
+
+			_, err := NewNetworks(nil, nil, networks).dockerNetworks()
+			Expect(err).To(MatchError(ContainSubstring("Expected at most one IPv4 and one IPv6 network to share a Docker network")))
+		})
+	})
+
+	Describe("splitNetworkSettings", func() {
+		It("creates the container with the endpoint of the highest gateway priority", func() {
+			netConfig := &dkrnet.NetworkingConfig{EndpointsConfig: map[string]*dkrnet.EndpointSettings{
+				"a": {}, "b": {GwPriority: primaryGwPriority}, "c": {},
+			}}
+
+			primary, others := splitNetworkSettings(netConfig)
+			Expect(primary.EndpointsConfig).To(HaveKey("b"))
+			Expect(primary.EndpointsConfig).To(HaveLen(1))
+			Expect(others).To(HaveLen(2))
+			Expect(others).To(HaveKey("a"))
+			Expect(others).To(HaveKey("c"))
+		})
+
+		It("picks the first network name among equal priorities", func() {
+			netConfig := &dkrnet.NetworkingConfig{EndpointsConfig: map[string]*dkrnet.EndpointSettings{
+				"c": {}, "a": {}, "b": {},
+			}}
+
+			for i := 0; i < 10; i++ {
+				primary, _ := splitNetworkSettings(&dkrnet.NetworkingConfig{EndpointsConfig: netConfig.EndpointsConfig})
+				Expect(primary.EndpointsConfig).To(HaveKey("a"))
+			}
+		})
+	})
+
+	Describe("networkRoutesCmds", func() {
+		It("pins the default route and routes other networks through their gateways", func() {
+			networks := unmarshalNetworks(`{
+				"default": {"type": "manual", "ip": "10.245.0.10", "netmask": "255.255.255.0", "gateway": "10.245.0.1", "default": ["dns", "gateway"], "cloud_properties": {}},
+				"db": {"type": "manual", "ip": "10.246.0.10", "netmask": "255.255.255.0", "gateway": "10.246.0.1", "cloud_properties": {}},
+				"v6": {"type": "manual", "ip": "fd00::10", "netmask": "ffff:ffff:ffff:ffff::", "gateway": "fd00::1", "cloud_properties": {}},
+				"dyn": {"type": "dynamic", "cloud_properties": {"name": "dyn"}},
+				"public": {"type": "vip", "ip": "203.0.113.10", "cloud_properties": {}}
+			}`)
+
+			Expect(networkRoutesCmds(networks)).To(Equal([]string{
+				"ip -4 route replace default via 10.246.0.1 table 101",
+				"{ ip -4 rule del from 10.246.0.10 table 101 2>/dev/null || true; }",
+				"ip -4 rule add from 10.246.0.10 table 101",
+				"ip -4 route replace default via 10.245.0.1",
+				"ip -6 route replace default via fd00::1 table 102",
+				"{ ip -6 rule del from fd00::10 table 102 2>/dev/null || true; }",
+				"ip -6 rule add from fd00::10 table 102",
+			}))
+		})
+	})
+
+	Describe("networkingConfig", func() {
+		It("requests IPv4 and IPv6 addresses on the same endpoint", func() {
+			networks := unmarshalNetworks(`{
+				"v4": {"type": "manual", "ip": "10.245.0.10", "netmask": "255.255.255.0"},
+				"v6": {"type": "manual", "ip": "fd00::10", "netmask": "ffff:ffff:ffff:ffff::"}
+			}`)
+
+			netConfig := Networks{}.networkingConfig([]netConfigPair{
+				{Name: "v4", Network: networks["v4"], Props: NetProps{Name: "bosh"}},
+				{Name: "v6", Network: networks["v6"], Props: NetProps{Name: "bosh"}},
+			})
+
+			Expect(netConfig.EndpointsConfig).To(HaveLen(1))
+			Expect(netConfig.EndpointsConfig["bosh"].GwPriority).To(BeZero())
+			Expect(netConfig.EndpointsConfig["bosh"].IPAMConfig).To(Equal(&dkrnet.EndpointIPAMConfig{
+				IPv4Address: "10.245.0.10",
+				IPv6Address: "fd00::10",
+			}))
+		})
+
+		It("prioritizes the endpoint of the default gateway network", func() {
+			networks := unmarshalNetworks(`{
+				"a": {"type": "manual", "ip": "10.245.0.10", "netmask": "255.255.255.0"},
+				"b": {"type": "manual", "ip": "10.246.0.10", "netmask": "255.255.255.0", "default": ["gateway"]}
+			}`)
+
+			netConfig := Networks{}.networkingConfig([]netConfigPair{
+				{Name: "a", Network: networks["a"], Props: NetProps{Name: "a"}},
+				{Name: "b", Network: networks["b"], Props: NetProps{Name: "b"}},
+			})
+
+			Expect(netConfig.EndpointsConfig["a"].GwPriority).To(BeZero())
+			Expect(netConfig.EndpointsConfig["b"].GwPriority).To(Equal(primaryGwPriority))
+		})
+	})
+
+	Describe("checkGateway", func() {
+		var (
+			network apiv1.Network
+		)
+
+		BeforeEach(func() {
+			network = apiv1.NewNetwork(apiv1.NetworkOpts{
+				Type: "manual", IP: "10.245.0.10", Netmask: "255.255.255.0", Gateway: "10.245.0.254",
+			})
+		})
+
+		existingWithGateway := func(subnet, gateway string) dkrnet.Inspect {
+			return dkrnet.Inspect{
+				Name: "bosh",
+				IPAM: dkrnet.IPAM{Config: []dkrnet.IPAMConfig{{Subnet: subnet, Gateway: gateway}}},
+			}
+		}
+
+		It("succeeds when the existing network has the same gateway", func() {
+			Expect(checkGateway(existingWithGateway("10.245.0.0/24", "10.245.0.254"), network)).To(Succeed())
+		})
+
+		It("returns error when the existing network has a different gateway", func() {
+			err := checkGateway(existingWithGateway("10.245.0.0/16", "10.245.0.1"), network)
+			Expect(err).To(MatchError(ContainSubstring(
+				"Expected existing network 'bosh' with subnet '10.245.0.0/16' to have gateway '10.245.0.254' but it has gateway '10.245.0.1'")))
+		})
+
+		It("ignores subnets that do not contain the network's IP", func() {
+			Expect(checkGateway(existingWithGateway("10.246.0.0/24", "10.246.0.1"), network)).To(Succeed())
+		})
+
+		It("succeeds when the BOSH network has no gateway", func() {
+			network = apiv1.NewNetwork(apiv1.NetworkOpts{Type: "manual", IP: "10.245.0.10", Netmask: "255.255.255.0"})
+			Expect(checkGateway(existingWithGateway("10.245.0.0/24", "10.245.0.1"), network)).To(Succeed())
+		})
+	})
+
+	Describe("checkGateways", func() {
+		var (
+			existing dkrnet.Inspect
+			pairs    []netConfigPair
+		)
+
+		BeforeEach(func() {
+			existing = dkrnet.Inspect{
+				Name: "bosh",
+				IPAM: dkrnet.IPAM{Config: []dkrnet.IPAMConfig{
+					{Subnet: "10.245.0.0/24", Gateway: "10.245.0.1"},
+					{Subnet: "fd00::/64", Gateway: "fd00::1"},
+				}},
+			}
+
+			networks := unmarshalNetworks(`{
+				"v4": {"type": "manual", "ip": "10.245.0.10", "netmask": "255.255.255.0", "gateway": "10.245.0.1"},
+				"v6": {"type": "manual", "ip": "fd00::10", "netmask": "ffff:ffff:ffff:ffff::", "gateway": "fd00::fe"}
+			}`)
+
+			pairs = []netConfigPair{
+				{Name: "v4", Network: networks["v4"], Props: NetProps{Name: "bosh"}},
+				{Name: "v6", Network: networks["v6"], Props: NetProps{Name: "bosh"}},
+			}
+		})
+
+		It("returns error when the gateway of a later address family differs", func() {
+			err := checkGateways(existing, pairs)
+			Expect(err).To(MatchError(ContainSubstring(
+				"Expected existing network 'bosh' with subnet 'fd00::/64' to have gateway 'fd00::fe' but it has gateway 'fd00::1'")))
+		})
+
+		It("succeeds when all gateways match", func() {
+			existing.IPAM.Config[1].Gateway = "fd00::fe"
+			Expect(checkGateways(existing, pairs)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,160 @@
+package vm
+
+import (
+	"fmt"
+	gonet "net"
+	"sort"
+	"strconv"
+	"strings"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	dkrcont "github.com/docker/docker/api/types/container"
+	dkrnat "github.com/docker/go-connections/nat"
+)
+
+// HostPortBindings parses 'port_bindings' into Docker port bindings.
+// Host ports are given as '[host_ip:]host_port' ('[::1]:2222' for IPv6).
+func (p Props) HostPortBindings() (dkrnat.PortMap, error) {
+	bindings := dkrnat.PortMap{}
+
+	for containerPort, hostPort := range p.HostPorts {
+		proto, portOrRange := dkrnat.SplitProtoPort(containerPort)
+
+		port, err := dkrnat.NewPort(proto, portOrRange)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err,
+				"Expected 'port_bindings' to map container ports like '22/tcp', got '%s'", containerPort)
+		}
+
+		hostIP, hostPortNum := "", hostPort
+
+		if strings.Contains(hostPort, ":") {
+			hostIP, hostPortNum, err = gonet.SplitHostPort(hostPort)
+			if err != nil || gonet.ParseIP(hostIP) == nil {
+				return nil, bosherr.Errorf(
+					"Expected 'port_bindings' to map '%s' to '[host_ip:]host_port', got '%s'", containerPort, hostPort)
+			}
+		}
+
+		num, err := strconv.Atoi(hostPortNum)
+		if err != nil || num < 1 || num > 65535 {
+			return nil, bosherr.Errorf(
+				"Expected 'port_bindings' to map '%s' to a host port between 1 and 65535, got '%s'", containerPort, hostPort)
+		}
+
+		bindings[port] = append(bindings[port], dkrnat.PortBinding{HostIP: hostIP, HostPort: hostPortNum})
+	}
+
+	return bindings, nil
+}
+
+// mergePortBindings returns bindings from both maps, e.g. those set
+// through 'PortBindings' and those from 'port_bindings'
+func mergePortBindings(a, b dkrnat.PortMap) dkrnat.PortMap {
+	merged := dkrnat.PortMap{}
+
+	for _, m := range []dkrnat.PortMap{a, b} {
+		for port, bindings := range m {
+			merged[port] = append(merged[port], bindings...)
+		}
+	}
+
+	return merged
+}
+
+// portConflicts checks fixed host ports against ports published by other
+// CPI containers, which Docker would only report when starting the container.
+func portConflicts(bindings dkrnat.PortMap, containers []dkrcont.Summary) error {
+	conflicts := map[string]struct{}{}
+
+	for _, cont := range containers {
+		name := cpiContainerName(cont)
+		if len(name) == 0 {
+			continue
+		}
+
+		for _, published := range cont.Ports {
+			if published.PublicPort == 0 {
+				continue
+			}
+
+			for port, portBindings := range bindings {
+				for _, binding := range portBindings {
+					if port.Proto() != published.Type || binding.HostPort != strconv.Itoa(int(published.PublicPort)) {
+						continue
+					}
+
+					if hostIPsOverlap(binding.HostIP, published.IP) {
+						conflicts[fmt.Sprintf("'%s' is published on '%s' by VM '%s'",
+							port, gonet.JoinHostPort(published.IP, binding.HostPort), name)] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		var msgs []string
+
+		for msg := range conflicts {
+			msgs = append(msgs, msg)
+		}
+
+		sort.Strings(msgs)
+
+		return bosherr.Errorf("Expected host ports to be free: %s", strings.Join(msgs, ", "))
+	}
+
+	return nil
+}
+
+// configuredPorts returns fixed host ports of port bindings in the form
+// ContainerList reports published ports of running containers
+func configuredPorts(bindings dkrnat.PortMap) []dkrcont.Port {
+	var ports []dkrcont.Port
+
+	for port, portBindings := range bindings {
+		for _, binding := range portBindings {
+			num, err := strconv.Atoi(binding.HostPort)
+			if err != nil || num < 1 || num > 65535 {
+				continue // random or ranged host port
+			}
+
+			hostIP := binding.HostIP
+			if len(hostIP) == 0 {
+				hostIP = "0.0.0.0"
+			}
+
+			ports = append(ports, dkrcont.Port{
+				IP:          hostIP,
+				PrivatePort: uint16(port.Int()),
+				PublicPort:  uint16(num),
+				Type:        port.Proto(),
+			})
+		}
+	}
+
+	return ports
+}
+
+func cpiContainerName(cont dkrcont.Summary) string {
+	for _, name := range cont.Names {
+		if strings.HasPrefix(name, "/c-") {
+			return strings.TrimPrefix(name, "/")
+		}
+	}
+
+	return ""
+}
+
+// hostIPsOverlap is true when both addresses are the same, or either is
+// empty or unspecified (0.0.0.0, ::) and so binds all addresses
+func hostIPsOverlap(a, b string) bool {
+	ipA, ipB := gonet.ParseIP(a), gonet.ParseIP(b)
+
+	if ipA == nil || ipB == nil || ipA.IsUnspecified() || ipB.IsUnspecified() {
+		return true
+	}
+
+	return ipA.Equal(ipB)
+}
@@ -0,0 +1,78 @@
+package vm
+
+import (
+	dkrcont "github.com/docker/docker/api/types/container"
+	dkrnat "github.com/docker/go-connections/nat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("portConflicts", func() {
+	var (
+		containers []dkrcont.Summary
+	)
+
+	BeforeEach(func() {
+		containers = []dkrcont.Summary{
+			{
+				Names: []string{"/c-jumpbox"},
+				Ports: []dkrcont.Port{
+					{IP: "0.0.0.0", PrivatePort: 22, PublicPort: 2222, Type: "tcp"},
+					{IP: "::", PrivatePort: 22, PublicPort: 2222, Type: "tcp"},
+					{PrivatePort: 6868, Type: "tcp"},
+				},
+			},
+			{
+				Names: []string{"/other"},
+				Ports: []dkrcont.Port{{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 8080, Type: "tcp"}},
+			},
+		}
+	})
+
+	It("returns error when another VM publishes the same host port", func() {
+		bindings := dkrnat.PortMap{"22/tcp": {{HostIP: "127.0.0.1", HostPort: "2222"}}}
+
+		err := portConflicts(bindings, containers)
+		Expect(err).To(MatchError("Expected host ports to be free: " +
+			"'22/tcp' is published on '0.0.0.0:2222' by VM 'c-jumpbox', " +
+			"'22/tcp' is published on '[::]:2222' by VM 'c-jumpbox'"))
+	})
+
+	It("succeeds for other protocols, addresses, random ports and non-CPI containers", func() {
+		containers[0].Ports = []dkrcont.Port{{IP: "10.0.0.1", PrivatePort: 22, PublicPort: 2222, Type: "tcp"}}
+
+		bindings := dkrnat.PortMap{
+			"22/udp":  {{HostPort: "2222"}},
+			"22/tcp":  {{HostIP: "10.0.0.2", HostPort: "2222"}},
+			"443/tcp": {{HostPort: ""}},
+			"80/tcp":  {{HostPort: "8080"}},
+		}
+
+		Expect(portConflicts(bindings, containers)).To(Succeed())
+	})
+})
+
+var _ = Describe("configuredPorts", func() {
+	It("returns fixed host ports of stopped containers", func() {
+		ports := configuredPorts(dkrnat.PortMap{
+			"22/tcp":  {{HostIP: "127.0.0.1", HostPort: "2222"}},
+			"443/tcp": {{HostPort: ""}},
+			"53/udp":  {{HostPort: "5353"}},
+		})
+
+		Expect(ports).To(ConsistOf(
+			dkrcont.Port{IP: "127.0.0.1", PrivatePort: 22, PublicPort: 2222, Type: "tcp"},
+			dkrcont.Port{IP: "0.0.0.0", PrivatePort: 53, PublicPort: 5353, Type: "udp"},
+		))
+	})
+
+	It("lets portConflicts detect ports held by stopped VMs", func() {
+		containers := []dkrcont.Summary{{
+			Names: []string{"/c-stopped"},
+			Ports: configuredPorts(dkrnat.PortMap{"22/tcp": {{HostPort: "2222"}}}),
+		}}
+
+		err := portConflicts(dkrnat.PortMap{"22/tcp": {{HostPort: "2222"}}}, containers)
+		Expect(err).To(MatchError("Expected host ports to be free: '22/tcp' is published on '0.0.0.0:2222' by VM 'c-stopped'"))
+	})
+})
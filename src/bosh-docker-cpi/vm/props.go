@@ -1,13 +1,23 @@
 package vm
 
 import (
+	gonet "net"
+
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
 	dkrcont "github.com/docker/docker/api/types/container"
 	specs "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"bosh-docker-cpi/config"
 )
 
 type Props struct {
 	ExposedPorts []string `json:"ports"` // [6868/tcp]
 
+	// HostPorts bind container ports to fixed host ports, e.g. {"22/tcp": "127.0.0.1:2222"}
+	HostPorts map[string]string `json:"port_bindings"`
+	// PublishAll replaces the CPI's 'publish_all_ports' when set
+	PublishAll *bool `json:"publish_all_ports"`
+
 	// Allow all Docker options
 	// ./src/github.com/docker/engine-api/types/container/host_config.go
 	dkrcont.HostConfig `json:",inline"`
@@ -17,6 +27,46 @@ type Props struct {
 	ForceStartWithoutSystemD bool `json:"force_start_without_systemd"`
 	ForceLXCFSEnabled        bool `json:"force_lxcfs_enabled"`
 	ForceLXCFSDisabled       bool `json:"force_lxcfs_disabled"`
+
+	EphemeralDisk EphemeralDiskProps `json:"ephemeral_disk"`
+
+	// Security replaces the CPI's security profile
+	Security *config.SecurityOpts `json:"security"`
+
+	// ResolvConf is merged with 'resolv_conf' of networks, overriding their options
+	ResolvConf ResolvConfProps `json:"resolv_conf"`
+}
+
+type EphemeralDiskProps struct {
+	Size int    `json:"size"` // MB; unlimited when 0
+	Type string `json:"type"` // loop (default) or tmpfs
+}
+
+func (p EphemeralDiskProps) TypeOrDefault() string {
+	if len(p.Type) == 0 {
+		return EphemeralDiskTypeLoop
+	}
+
+	return p.Type
+}
+
+func (p EphemeralDiskProps) Validate() error {
+	if p.Size < 0 {
+		return bosherr.Errorf("Expected 'size' to not be negative, got %d", p.Size)
+	}
+
+	switch p.TypeOrDefault() {
+	case EphemeralDiskTypeLoop, EphemeralDiskTypeTmpfs:
+	default:
+		return bosherr.Errorf("Expected 'type' to be '%s' or '%s', got '%s'",
+			EphemeralDiskTypeLoop, EphemeralDiskTypeTmpfs, p.Type)
+	}
+
+	if len(p.Type) > 0 && p.Size == 0 {
+		return bosherr.Error("Expected 'size' to be set when 'type' is set")
+	}
+
+	return nil
 }
 
 type NetProps struct {
@@ -24,4 +74,107 @@ type NetProps struct {
 	Driver string
 
 	EnableIPv6 bool `json:"enable_ipv6"` // useful for dynamic networks since they don't specify subnet
+
+	// Driver specific options, e.g. {"parent": "eth1", "macvlan_mode": "bridge"}
+	DriverOpts map[string]string `json:"driver_opts"`
+	Labels     map[string]string `json:"labels"`
+
+	Internal   bool `json:"internal"` // no route to or from outside of the network
+	Attachable bool `json:"attachable"`
+
+	// Keep Docker from handing out BOSH reserved IPs to other containers
+	// on manual networks: it only allocates from IPRange and never
+	// allocates AuxiliaryAddresses (e.g. {"dhcp": "10.245.0.2"})
+	IPRange            string            `json:"ip_range"`
+	AuxiliaryAddresses map[string]string `json:"auxiliary_addresses"`
+
+	// Recreate existing networks that do not match the options above instead of failing
+	Reconcile bool `json:"reconcile"`
+
+	ResolvConf ResolvConfProps `json:"resolv_conf"`
+}
+
+// minMemory is the smallest memory limit Docker accepts
+const minMemory = 6 * 1024 * 1024
+
+// Validate checks resource limits up front so that mistakes in vm_types
+// (or vm_resources) are reported with the cloud property names instead of
+// a Docker API error halfway through creating the container.
+func (p Props) Validate() error {
+	if p.Memory < 0 || p.MemorySwap < -1 || p.NanoCPUs < 0 || p.CPUShares < 0 {
+		return bosherr.Error("Expected 'Memory', 'MemorySwap', 'NanoCpus' and 'CpuShares' to not be negative")
+	}
+
+	if p.Memory > 0 && p.Memory < minMemory {
+		return bosherr.Errorf("Expected 'Memory' to be at least %d bytes, got %d", minMemory, p.Memory)
+	}
+
+	if p.MemorySwap > 0 {
+		if p.Memory == 0 {
+			return bosherr.Error("Expected 'Memory' to be set when 'MemorySwap' is set")
+		}
+
+		if p.MemorySwap < p.Memory {
+			return bosherr.Errorf("Expected 'MemorySwap' to be at least 'Memory' (%d), got %d", p.Memory, p.MemorySwap)
+		}
+	}
+
+	if p.NanoCPUs > 0 && (p.CPUQuota > 0 || p.CPUPeriod > 0) {
+		return bosherr.Error("Expected 'NanoCpus' to not be combined with 'CpuQuota' or 'CpuPeriod'")
+	}
+
+	_, err := p.HostPortBindings()
+	if err != nil {
+		return err
+	}
+
+	err = p.ResolvConf.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating 'resolv_conf'")
+	}
+
+	err = p.EphemeralDisk.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating 'ephemeral_disk'")
+	}
+
+	if p.Security != nil {
+		err = p.Security.Validate()
+		if err != nil {
+			return bosherr.WrapError(err, "Validating 'security'")
+		}
+	}
+
+	return nil
+}
+
+func (p NetProps) Validate() error {
+	err := p.ResolvConf.Validate()
+	if err != nil {
+		return bosherr.WrapError(err, "Validating 'resolv_conf'")
+	}
+
+	if len(p.IPRange) > 0 {
+		_, _, err := gonet.ParseCIDR(p.IPRange)
+		if err != nil {
+			return bosherr.Errorf("Expected 'ip_range' to be a CIDR, got '%s'", p.IPRange)
+		}
+	}
+
+	for name, addr := range p.AuxiliaryAddresses {
+		if gonet.ParseIP(addr) == nil {
+			return bosherr.Errorf("Expected 'auxiliary_addresses' to map names to IPs, got '%s' for '%s'", addr, name)
+		}
+	}
+
+	return nil
+}
+
+// shared returns properties that apply to the whole Docker network rather
+// than to the subnet of a single BOSH network
+func (p NetProps) shared() NetProps {
+	p.IPRange = ""
+	p.AuxiliaryAddresses = nil
+	p.ResolvConf = ResolvConfProps{}
+	return p
 }
@@ -3,6 +3,7 @@ package vm_test
 import (
 	"encoding/json"
 
+	dkrnat "github.com/docker/go-connections/nat"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -44,6 +45,13 @@ var _ = Describe("Props", func() {
 			Expect(props.ForceLXCFSDisabled).To(BeFalse())
 		})
 
+		It("unmarshals ephemeral disk size and type", func() {
+			var props Props
+			err := json.Unmarshal([]byte(`{"ephemeral_disk": {"size": 1024, "type": "tmpfs"}}`), &props)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(props.EphemeralDisk).To(Equal(EphemeralDiskProps{Size: 1024, Type: "tmpfs"}))
+		})
+
 		It("defaults all force flags to false", func() {
 			var props Props
 			err := json.Unmarshal([]byte(`{}`), &props)
@@ -54,4 +62,96 @@ var _ = Describe("Props", func() {
 			Expect(props.ForceLXCFSDisabled).To(BeFalse())
 		})
 	})
+
+	Describe("EphemeralDiskProps", func() {
+		It("defaults to loop files", func() {
+			Expect(EphemeralDiskProps{}.TypeOrDefault()).To(Equal("loop"))
+			Expect(EphemeralDiskProps{Type: "tmpfs"}.TypeOrDefault()).To(Equal("tmpfs"))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts empty props", func() {
+			Expect(Props{}.Validate()).To(Succeed())
+		})
+
+		It("accepts memory, swap and CPU limits", func() {
+			var props Props
+			err := json.Unmarshal([]byte(`{"Memory": 1073741824, "MemorySwap": -1, "NanoCpus": 1500000000, "CpuShares": 512}`), &props)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(props.Validate()).To(Succeed())
+		})
+
+		It("rejects negative limits", func() {
+			var props Props
+			props.NanoCPUs = -1
+			Expect(props.Validate()).To(MatchError(ContainSubstring("to not be negative")))
+		})
+
+		It("rejects memory below Docker's minimum", func() {
+			var props Props
+			props.Memory = 1024
+			Expect(props.Validate()).To(MatchError(ContainSubstring("'Memory' to be at least")))
+		})
+
+		It("rejects swap without or below the memory limit", func() {
+			var props Props
+			props.MemorySwap = 1 << 30
+			Expect(props.Validate()).To(MatchError(ContainSubstring("'Memory' to be set")))
+
+			props.Memory = 2 << 30
+			Expect(props.Validate()).To(MatchError(ContainSubstring("'MemorySwap' to be at least")))
+		})
+
+		It("rejects invalid ephemeral disks", func() {
+			props := Props{EphemeralDisk: EphemeralDiskProps{Size: -1}}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("'size' to not be negative")))
+
+			props = Props{EphemeralDisk: EphemeralDiskProps{Size: 1024, Type: "xfs"}}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("'type' to be 'loop' or 'tmpfs'")))
+
+			props = Props{EphemeralDisk: EphemeralDiskProps{Type: "tmpfs"}}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("'size' to be set")))
+		})
+
+		It("rejects NanoCpus combined with a CFS quota", func() {
+			var props Props
+			props.NanoCPUs = 1e9
+			props.CPUQuota = 50000
+			Expect(props.Validate()).To(MatchError(ContainSubstring("'NanoCpus' to not be combined")))
+		})
+	})
+
+	Describe("HostPortBindings", func() {
+		It("parses fixed host ports without shadowing Docker's PortBindings", func() {
+			var props Props
+			err := json.Unmarshal([]byte(`{
+				"port_bindings": {"22/tcp": "127.0.0.1:2222", "25555": "25555", "53/udp": "[::1]:5353"},
+				"publish_all_ports": false,
+				"PortBindings": {"8080/tcp": [{"HostPort": "80"}]}
+			}`), &props)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(props.PublishAll).To(HaveValue(BeFalse()))
+			Expect(props.HostConfig.PortBindings).To(HaveKey(dkrnat.Port("8080/tcp")))
+
+			bindings, err := props.HostPortBindings()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bindings).To(Equal(dkrnat.PortMap{
+				"22/tcp":    {{HostIP: "127.0.0.1", HostPort: "2222"}},
+				"25555/tcp": {{HostPort: "25555"}},
+				"53/udp":    {{HostIP: "::1", HostPort: "5353"}},
+			}))
+		})
+
+		It("returns error for invalid container or host ports", func() {
+			props := Props{HostPorts: map[string]string{"ssh": "2222"}}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("Expected 'port_bindings' to map container ports like '22/tcp'")))
+
+			props = Props{HostPorts: map[string]string{"22/tcp": "localhost:2222"}}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("to '[host_ip:]host_port', got 'localhost:2222'")))
+
+			props = Props{HostPorts: map[string]string{"22/tcp": "70000"}}
+			Expect(props.Validate()).To(MatchError(ContainSubstring("to a host port between 1 and 65535, got '70000'")))
+		})
+	})
 })
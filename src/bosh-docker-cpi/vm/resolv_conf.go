@@ -0,0 +1,181 @@
+package vm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	dkrnet "github.com/docker/docker/api/types/network"
+)
+
+// resolvConfEntryRegexp keeps entries safe to embed in the container's bash command
+var resolvConfEntryRegexp = regexp.MustCompile(`^[A-Za-z0-9._:-]+$`)
+
+// ResolvConfProps are written to /etc/resolv.conf before the agent starts,
+// so that processes started before BOSH DNS resolve short names
+type ResolvConfProps struct {
+	Search  []string `json:"search"`  // e.g. [service.cf.internal]
+	Options []string `json:"options"` // e.g. [ndots:2, timeout:1]
+}
+
+func (p ResolvConfProps) Validate() error {
+	for _, domain := range p.Search {
+		if !resolvConfEntryRegexp.MatchString(domain) {
+			return bosherr.Errorf("Expected 'resolv_conf.search' to contain domain names, got '%s'", domain)
+		}
+	}
+
+	for _, opt := range p.Options {
+		if !resolvConfEntryRegexp.MatchString(opt) {
+			return bosherr.Errorf("Expected 'resolv_conf.options' to contain options like 'ndots:2', got '%s'", opt)
+		}
+	}
+
+	return nil
+}
+
+// Merge returns search domains of both, and options of p overridden by
+// options of other with the same name (e.g. 'ndots').
+func (p ResolvConfProps) Merge(other ResolvConfProps) ResolvConfProps {
+	var merged ResolvConfProps
+
+	for _, domain := range append(append([]string{}, p.Search...), other.Search...) {
+		if !containsString(merged.Search, domain) {
+			merged.Search = append(merged.Search, domain)
+		}
+	}
+
+	for _, opt := range append(append([]string{}, p.Options...), other.Options...) {
+		name := strings.SplitN(opt, ":", 2)[0]
+		replaced := false
+
+		for i, mergedOpt := range merged.Options {
+			if strings.SplitN(mergedOpt, ":", 2)[0] == name {
+				merged.Options[i] = opt
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			merged.Options = append(merged.Options, opt)
+		}
+	}
+
+	return merged
+}
+
+// resolvConfProps merges 'resolv_conf' of networks (by network name) and of the VM type
+func resolvConfProps(networks apiv1.Networks, vmResolvConf ResolvConfProps) (ResolvConfProps, error) {
+	var resolvConf ResolvConfProps
+
+	for _, name := range sortedNetworkNames(networks) {
+		var netProps NetProps
+
+		err := networks[name].CloudProps().As(&netProps)
+		if err != nil {
+			return ResolvConfProps{}, bosherr.WrapErrorf(err, "Unmarshaling properties of network '%s'", name)
+		}
+
+		resolvConf = resolvConf.Merge(netProps.ResolvConf)
+	}
+
+	return resolvConf.Merge(vmResolvConf), nil
+}
+
+func populateResolveConf(networks apiv1.Networks, resolvConf ResolvConfProps) string {
+	var nameserverEntries []string
+	for _, name := range sortedNetworkNames(networks) {
+		for _, dnsServer := range networks[name].DNS() {
+			nameserverEntries = append(nameserverEntries, fmt.Sprintf(`"nameserver %s"`, dnsServer))
+		}
+	}
+
+	if len(resolvConf.Search) > 0 {
+		nameserverEntries = append(nameserverEntries, fmt.Sprintf(`"search %s"`, strings.Join(resolvConf.Search, " ")))
+	}
+
+	if len(resolvConf.Options) > 0 {
+		nameserverEntries = append(nameserverEntries, fmt.Sprintf(`"options %s"`, strings.Join(resolvConf.Options, " ")))
+	}
+
+	if len(nameserverEntries) == 0 {
+		return ":" // no-op bash command
+	}
+
+	return fmt.Sprintf(`printf '%%s\n' %s > /etc/resolv.conf`, strings.Join(nameserverEntries, " "))
+}
+
+// populateResolvedConf configures search domains of systemd-resolved, which
+// manages /etc/resolv.conf in containers started with systemd; resolved has
+// no equivalent of resolv.conf options.
+func populateResolvedConf(resolvConf ResolvConfProps) string {
+	if len(resolvConf.Search) == 0 {
+		return ":" // no-op bash command
+	}
+
+	return fmt.Sprintf(`mkdir -p /etc/systemd/resolved.conf.d && `+
+		`printf '%%s\n' "[Resolve]" "Domains=%s" > /etc/systemd/resolved.conf.d/bosh-cpi.conf`,
+		strings.Join(resolvConf.Search, " "))
+}
+
+// hostnameRegexp matches names that are valid hostnames and safe to embed in
+// the container's bash command
+var hostnameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.-]{0,62}$`)
+
+// vmHostname is the agent ID like on other IaaSes, falling back to the VM CID
+// for agent IDs that are not valid hostnames
+func vmHostname(agentID apiv1.AgentID, id apiv1.VMCID) string {
+	if hostnameRegexp.MatchString(agentID.AsString()) {
+		return agentID.AsString()
+	}
+
+	return id.AsString()
+}
+
+// populateEtcHosts maps the instance's hostname to its address on the default
+// gateway network, i.e. the endpoint the container is created with (see
+// splitNetworkSettings). Addresses of dynamic networks are only known once the
+// container runs, so the source address of the default route is used for them.
+func populateEtcHosts(hostname string, primary map[string]*dkrnet.EndpointSettings) string {
+	ip := `$({ ip -o -4 route get 1.0.0.0 || ip -o -6 route get 2000::; } 2>/dev/null | sed -n 's/.* src \([^ ]*\) .*/\1/p')`
+
+	for _, endPtConfig := range primary {
+		if endPtConfig == nil || endPtConfig.IPAMConfig == nil {
+			continue
+		}
+
+		if len(endPtConfig.IPAMConfig.IPv4Address) > 0 {
+			ip = endPtConfig.IPAMConfig.IPv4Address
+		} else if len(endPtConfig.IPAMConfig.IPv6Address) > 0 {
+			ip = endPtConfig.IPAMConfig.IPv6Address
+		}
+	}
+
+	return fmt.Sprintf(`printf '%%s\n' "127.0.0.1 localhost" "::1 localhost ip6-localhost ip6-loopback" `+
+		`"%s %s" > /etc/hosts`, ip, hostname)
+}
+
+func sortedNetworkNames(networks apiv1.Networks) []string {
+	var names []string
+
+	for name := range networks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+
+	return false
+}
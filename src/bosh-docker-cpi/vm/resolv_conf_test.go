@@ -0,0 +1,107 @@
+package vm
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	dkrnet "github.com/docker/docker/api/types/network"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ResolvConf", func() {
+	var (
+		networks apiv1.Networks
+	)
+
+	BeforeEach(func() {
+		err := json.Unmarshal([]byte(`{
+			"b": {"type": "dynamic", "dns": ["10.0.0.3"], "cloud_properties": {"name": "b", "resolv_conf": {"search": ["b.internal"], "options": ["ndots:5"]}}},
+			"a": {"type": "manual", "ip": "10.245.0.10", "netmask": "255.255.255.0", "default": ["gateway"], "dns": ["10.0.0.1", "10.0.0.2"],
+				"cloud_properties": {"resolv_conf": {"search": ["a.internal", "b.internal"], "options": ["timeout:1"]}}}
+		}`), &networks)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("resolvConfProps", func() {
+		It("merges search domains and lets VM options override network options", func() {
+			resolvConf, err := resolvConfProps(networks, ResolvConfProps{Search: []string{"vm.internal"}, Options: []string{"ndots:2"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resolvConf).To(Equal(ResolvConfProps{
+				Search:  []string{"a.internal", "b.internal", "vm.internal"},
+				Options: []string{"timeout:1", "ndots:2"},
+			}))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("rejects entries that are not safe to write", func() {
+			Expect(ResolvConfProps{Search: []string{"a.internal"}, Options: []string{"ndots:2", "rotate"}}.Validate()).To(Succeed())
+
+			err := ResolvConfProps{Search: []string{"a b"}}.Validate()
+			Expect(err).To(MatchError(ContainSubstring("Expected 'resolv_conf.search' to contain domain names")))
+
+			err = ResolvConfProps{Options: []string{`ndots:2"; reboot; "`}}.Validate()
+			Expect(err).To(MatchError(ContainSubstring("Expected 'resolv_conf.options' to contain options like 'ndots:2'")))
+		})
+	})
+
+	Describe("populateResolveConf", func() {
+		It("writes nameservers by network name followed by search domains and options", func() {
+			cmd := populateResolveConf(networks, ResolvConfProps{Search: []string{"a.internal"}, Options: []string{"ndots:2"}})
+			Expect(cmd).To(Equal(`printf '%s\n' "nameserver 10.0.0.1" "nameserver 10.0.0.2" "nameserver 10.0.0.3" ` +
+				`"search a.internal" "options ndots:2" > /etc/resolv.conf`))
+		})
+
+		It("does nothing without any entries", func() {
+			Expect(populateResolveConf(apiv1.Networks{}, ResolvConfProps{})).To(Equal(":"))
+		})
+	})
+
+	Describe("populateResolvedConf", func() {
+		It("configures search domains of systemd-resolved", func() {
+			Expect(populateResolvedConf(ResolvConfProps{Search: []string{"a.internal", "b.internal"}})).To(Equal(
+				`mkdir -p /etc/systemd/resolved.conf.d && ` +
+					`printf '%s\n' "[Resolve]" "Domains=a.internal b.internal" > /etc/systemd/resolved.conf.d/bosh-cpi.conf`))
+
+			Expect(populateResolvedConf(ResolvConfProps{Options: []string{"ndots:2"}})).To(Equal(":"))
+		})
+	})
+
+	Describe("populateEtcHosts", func() {
+		It("maps the hostname to the address of the endpoint the container is created with", func() {
+			primary := map[string]*dkrnet.EndpointSettings{
+				"a": {IPAMConfig: &dkrnet.EndpointIPAMConfig{IPv4Address: "10.245.0.10", IPv6Address: "fd00::10"}},
+			}
+
+			Expect(populateEtcHosts("agent-123", primary)).To(Equal(
+				`printf '%s\n' "127.0.0.1 localhost" "::1 localhost ip6-localhost ip6-loopback" "10.245.0.10 agent-123" > /etc/hosts`))
+		})
+
+		It("uses the IPv6 address of IPv6-only endpoints", func() {
+			primary := map[string]*dkrnet.EndpointSettings{
+				"a": {IPAMConfig: &dkrnet.EndpointIPAMConfig{IPv6Address: "fd00::10"}},
+			}
+
+			Expect(populateEtcHosts("agent-123", primary)).To(ContainSubstring(`"fd00::10 agent-123"`))
+		})
+
+		It("looks up the source address of the default route for dynamic networks when the container starts", func() {
+			primary := map[string]*dkrnet.EndpointSettings{"b": {}}
+
+			Expect(populateEtcHosts("agent-123", primary)).To(ContainSubstring(
+				`"$({ ip -o -4 route get 1.0.0.0 || ip -o -6 route get 2000::; } 2>/dev/null | sed -n 's/.* src \([^ ]*\) .*/\1/p') agent-123" > /etc/hosts`))
+		})
+	})
+
+	Describe("vmHostname", func() {
+		It("uses the agent ID", func() {
+			Expect(vmHostname(apiv1.NewAgentID("3f1e2a7c-0b8d-4f64-9a51-2c7e0d9b6a15"), apiv1.NewVMCID("c-123"))).To(
+				Equal("3f1e2a7c-0b8d-4f64-9a51-2c7e0d9b6a15"))
+		})
+
+		It("falls back to the VM CID for agent IDs that are not valid hostnames", func() {
+			Expect(vmHostname(apiv1.NewAgentID("agent $(id)"), apiv1.NewVMCID("c-123"))).To(Equal("c-123"))
+		})
+	})
+})
@@ -0,0 +1,55 @@
+package vm
+
+import (
+	"strings"
+
+	dkrcont "github.com/docker/docker/api/types/container"
+
+	"bosh-docker-cpi/config"
+)
+
+// applySecurity restricts hostConfig to the security profile. Privileged,
+// capabilities and security options set directly through vm_type cloud
+// properties are kept and take precedence.
+func applySecurity(hostConfig *dkrcont.HostConfig, opts config.SecurityOpts) {
+	if opts.Privileged || hostConfig.Privileged {
+		hostConfig.Privileged = true
+		return
+	}
+
+	hostConfig.CapAdd = append(append([]string{}, opts.CapabilitiesOrDefault()...), hostConfig.CapAdd...)
+
+	securityOpts := []string{"apparmor=" + opts.AppArmorOrDefault()}
+
+	if len(opts.Seccomp) > 0 {
+		securityOpts = append(securityOpts, "seccomp="+opts.Seccomp)
+	}
+
+	if opts.NoNewPrivileges {
+		securityOpts = append(securityOpts, "no-new-privileges=true")
+	}
+
+	for _, securityOpt := range securityOpts {
+		if !hasSecurityOpt(hostConfig.SecurityOpt, securityOpt) {
+			hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, securityOpt)
+		}
+	}
+}
+
+// hasSecurityOpt reports whether opts already configure the key of opt
+// (e.g. "apparmor" of "apparmor=unconfined"). Docker also accepts ':' as separator.
+func hasSecurityOpt(opts []string, opt string) bool {
+	key := securityOptKey(opt)
+
+	for _, o := range opts {
+		if securityOptKey(o) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func securityOptKey(opt string) string {
+	return strings.SplitN(strings.SplitN(opt, "=", 2)[0], ":", 2)[0]
+}
@@ -0,0 +1,55 @@
+package vm
+
+import (
+	"sort"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	bosherr "github.com/cloudfoundry/bosh-utils/errors"
+	dkrnat "github.com/docker/go-connections/nat"
+)
+
+// VIPNetworkType is the BOSH network type of addresses owned by the Docker host;
+// containers are not connected to a Docker network for them
+const VIPNetworkType = "vip"
+
+// vipPortBindings publishes exposed ports on the addresses of vip networks,
+// keeping the container's port as host port, in addition to existing bindings.
+func vipPortBindings(networks apiv1.Networks, exposedPorts []string, bindings dkrnat.PortMap) (dkrnat.PortMap, error) {
+	var vipNames []string
+
+	for name, net := range networks {
+		if net.Type() == VIPNetworkType {
+			vipNames = append(vipNames, name)
+		}
+	}
+
+	if len(vipNames) == 0 {
+		return bindings, nil
+	}
+
+	sort.Strings(vipNames)
+
+	vipBindings := dkrnat.PortMap{}
+
+	for port, portBindings := range bindings {
+		vipBindings[port] = portBindings
+	}
+
+	for _, exposedPort := range exposedPorts {
+		proto, portOrRange := dkrnat.SplitProtoPort(exposedPort)
+
+		port, err := dkrnat.NewPort(proto, portOrRange)
+		if err != nil {
+			return nil, bosherr.WrapErrorf(err, "Expected 'ports' to contain ports like '6868/tcp', got '%s'", exposedPort)
+		}
+
+		for _, name := range vipNames {
+			vipBindings[port] = append(vipBindings[port], dkrnat.PortBinding{
+				HostIP:   networks[name].IP(),
+				HostPort: port.Port(),
+			})
+		}
+	}
+
+	return vipBindings, nil
+}
@@ -0,0 +1,50 @@
+package vm
+
+import (
+	"encoding/json"
+
+	"github.com/cloudfoundry/bosh-cpi-go/apiv1"
+	dkrnat "github.com/docker/go-connections/nat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("vipPortBindings", func() {
+	var (
+		networks apiv1.Networks
+	)
+
+	BeforeEach(func() {
+		err := json.Unmarshal([]byte(`{
+			"default": {"type": "dynamic", "cloud_properties": {"name": "bosh"}},
+			"public": {"type": "vip", "ip": "203.0.113.10", "cloud_properties": {}}
+		}`), &networks)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("publishes exposed ports on vip addresses in addition to existing bindings", func() {
+		existing := dkrnat.PortMap{"22/tcp": {{HostIP: "127.0.0.1", HostPort: "2222"}}}
+
+		bindings, err := vipPortBindings(networks, []string{"443/tcp", "53/udp", "25555"}, existing)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bindings).To(Equal(dkrnat.PortMap{
+			"22/tcp":    {{HostIP: "127.0.0.1", HostPort: "2222"}},
+			"443/tcp":   {{HostIP: "203.0.113.10", HostPort: "443"}},
+			"53/udp":    {{HostIP: "203.0.113.10", HostPort: "53"}},
+			"25555/tcp": {{HostIP: "203.0.113.10", HostPort: "25555"}},
+		}))
+	})
+
+	It("keeps bindings as they are without vip networks", func() {
+		delete(networks, "public")
+
+		bindings, err := vipPortBindings(networks, []string{"443/tcp"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bindings).To(BeNil())
+	})
+
+	It("returns error for invalid ports", func() {
+		_, err := vipPortBindings(networks, []string{"https/tcp"}, nil)
+		Expect(err).To(MatchError(ContainSubstring("Expected 'ports' to contain ports like '6868/tcp', got 'https/tcp'")))
+	})
+})